@@ -3,33 +3,106 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"github.com/pachmu/skyeng-push-notificator/config"
 	"github.com/pachmu/skyeng-push-notificator/internal/bot"
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
+	"github.com/pachmu/skyeng-push-notificator/internal/pushover"
+	"github.com/pachmu/skyeng-push-notificator/internal/schedule"
 	"github.com/pachmu/skyeng-push-notificator/internal/sender"
 	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
 	"github.com/pachmu/skyeng-push-notificator/internal/state"
 	"github.com/pachmu/skyeng-push-notificator/internal/storage"
+	"github.com/pachmu/skyeng-push-notificator/server"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 var configPath = flag.String("config", "./config/config.yaml", "Path to config file")
 
+const (
+	channelTelegram = "telegram"
+	channelPushover = "pushover"
+	channelEmail    = "email"
+	channelWebhook  = "webhook"
+	channelNtfy     = "ntfy"
+)
+
+// defaultSnapshotSyncInterval is used when snapshot sync interval is not configured.
+const defaultSnapshotSyncInterval = 6 * time.Hour
+
 func main() {
 	flag.Parse()
 	conf, err := config.GetConfig(*configPath)
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	skyengClient := skyeng.NewClient(conf.Skyeng.User, conf.Skyeng.Password)
+	skyengOpts := []skyeng.Option{
+		skyeng.WithEndpoints(conf.Skyeng.AuthEndpoint, conf.Skyeng.WordsEndpoint, conf.Skyeng.DictEndpoint),
+		skyeng.WithTimeout(conf.Skyeng.Timeout),
+		skyeng.WithRetryPolicy(skyeng.RetryPolicy{
+			MaxAttempts: conf.Skyeng.Retry.MaxAttempts,
+			BaseDelay:   conf.Skyeng.Retry.BaseDelay,
+			MaxDelay:    conf.Skyeng.Retry.MaxDelay,
+		}),
+	}
+	if conf.Skyeng.SessionFile != "" {
+		skyengOpts = append(skyengOpts, skyeng.WithTokenStore(skyeng.NewFileTokenStore(conf.Skyeng.SessionFile)))
+	}
+	liveClient := skyeng.NewClient(conf.Skyeng.User, conf.Skyeng.Password, skyengOpts...)
+	var baseClient skyeng.Client = liveClient
+	if conf.Snapshot.FilePath != "" {
+		baseClient = skyeng.NewFallbackClient(liveClient, skyeng.NewSnapshotClient(conf.Snapshot.FilePath))
+	}
+	skyengClient := skyeng.NewCachingClient(
+		baseClient,
+		skyeng.CacheOptions{
+			WordsetsTTL: conf.Cache.WordsetsTTL,
+			WordsTTL:    conf.Cache.WordsTTL,
+			MeaningsTTL: conf.Cache.MeaningsTTL,
+			MaxEntries:  conf.Cache.MaxEntries,
+		},
+	)
 
 	st := state.NewState(conf.SendInterval)
-	sndr := sender.NewSender(st)
+	if conf.Schedule != "" {
+		loc, err := schedule.LoadLocation(conf.Timezone)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		sched, err := schedule.Parse(conf.Schedule, loc)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		st.SetSchedule(sched)
+	}
+	dataStorage := storage.NewYamlStorage(conf.YamlStorage.FilePath)
+	handler := bot.NewMessageHandler(conf.Bot.User, skyengClient, st, dataStorage)
+	pushoverClient := pushover.NewClient(conf.Pushover.Token, conf.Pushover.User, conf.Pushover.Device)
+	if conf.Pushover.Glances {
+		handler.OnStats(notifier.NewPushoverGlance(pushoverClient, conf.Pushover.DailyGoal))
+	}
+	ntfy := notifier.NewNtfy(notifier.NtfyOptions{
+		Server:         conf.Ntfy.Server,
+		Topic:          conf.Ntfy.Topic,
+		Token:          conf.Ntfy.Token,
+		Priority:       conf.Ntfy.Priority,
+		Tags:           conf.Ntfy.Tags,
+		Click:          conf.Ntfy.Click,
+		CallbackURL:    conf.Ntfy.CallbackURL,
+		CallbackSecret: conf.Ntfy.CallbackSecret,
+	})
 	ctx, cancel := context.WithCancel(context.Background())
 	errGr, ctx := errgroup.WithContext(ctx)
+	ntf, digests, err := newNotifier(ctx, conf, handler, pushoverClient, ntfy)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	sndr := sender.NewSender(st, ntf)
 	errGr.Go(func() error {
 		err := sndr.Run(ctx)
 		if err != nil {
@@ -38,9 +111,25 @@ func main() {
 		return nil
 	})
 	logrus.Info("Sender started")
+	for _, digest := range digests {
+		digest := digest
+		errGr.Go(func() error {
+			return digest.Run(ctx)
+		})
+	}
+
+	if conf.Snapshot.FilePath != "" {
+		syncInterval := conf.Snapshot.SyncInterval
+		if syncInterval == 0 {
+			syncInterval = defaultSnapshotSyncInterval
+		}
+		syncer := skyeng.NewSnapshotSyncer(liveClient, conf.Snapshot.FilePath, syncInterval)
+		errGr.Go(func() error {
+			return syncer.Run(ctx)
+		})
+		logrus.Info("Snapshot sync started")
+	}
 
-	dataStorage := storage.NewYamlStorage(conf.YamlStorage.FilePath)
-	handler := bot.NewMessageHandler(conf.Bot.User, skyengClient, st, dataStorage)
 	bt, err := bot.NewTelegramBot(conf.Bot.Token, handler)
 	if err != nil {
 		logrus.Fatal(err)
@@ -55,6 +144,15 @@ func main() {
 	})
 	logrus.Info("Bot started")
 
+	srv := &server.Server{
+		Port:       conf.Port,
+		Answers:    handler,
+		Examples:   ntfy,
+		NtfySecret: conf.Ntfy.CallbackSecret,
+	}
+	errGr.Go(func() error {
+		return srv.Serve(ctx, conf.Bot.User, skyengClient, handler, handler)
+	})
 	logrus.Infof("Server started on port %d", conf.Port)
 	errGr.Go(func() error {
 		quitCh := make(chan os.Signal, 1)
@@ -71,3 +169,71 @@ func main() {
 	}
 	logrus.Info("Process terminated")
 }
+
+// newNotifier returns notifier delivering scheduled pushes to configured channels
+// along with digests to run in background, ctx is the application lifetime.
+func newNotifier(ctx context.Context, conf *config.Config, handler *bot.MessageHandler, pushoverClient pushover.Client, ntfy *notifier.Ntfy) (notifier.Notifier, []*notifier.Digest, error) {
+	channels := conf.Notify
+	if len(channels) == 0 {
+		channels = []string{channelTelegram}
+	}
+	var notifiers []notifier.Notifier
+	var digests []*notifier.Digest
+	for _, ch := range channels {
+		switch ch {
+		case channelTelegram:
+			notifiers = append(notifiers, handler.Notifier())
+		case channelPushover:
+			notifiers = append(notifiers, notifier.NewPushover(
+				ctx,
+				pushoverClient,
+				notifier.PushoverOptions{
+					Priority:       conf.Pushover.Priority,
+					Sound:          conf.Pushover.Sound,
+					HTML:           conf.Pushover.HTML,
+					URL:            conf.Pushover.URL,
+					URLTitle:       conf.Pushover.URLTitle,
+					TTL:            conf.Pushover.TTL,
+					Retry:          conf.Pushover.Retry,
+					Expire:         conf.Pushover.Expire,
+					AttachImage:    conf.Pushover.AttachImage,
+					LowQuota:       conf.Pushover.LowQuota,
+					DigestInterval: conf.Pushover.DigestInterval,
+				},
+			))
+		case channelEmail:
+			email := notifier.NewEmail(notifier.EmailOptions{
+				Addr:     conf.Email.Addr,
+				Username: conf.Email.Username,
+				Password: conf.Email.Password,
+				From:     conf.Email.From,
+				To:       conf.Email.To,
+				Subject:  conf.Email.Subject,
+			})
+			if conf.Email.DigestPeriod == 0 {
+				notifiers = append(notifiers, email)
+				continue
+			}
+			digest := notifier.NewDigest(email, conf.Email.DigestPeriod)
+			digests = append(digests, digest)
+			notifiers = append(notifiers, digest)
+		case channelWebhook:
+			var endpoints []notifier.WebhookEndpoint
+			for _, e := range conf.Webhook.Endpoints {
+				endpoints = append(endpoints, notifier.WebhookEndpoint{URL: e.URL, Secret: e.Secret})
+			}
+			notifiers = append(notifiers, notifier.NewWebhook(notifier.WebhookOptions{
+				Endpoints:   endpoints,
+				MaxAttempts: conf.Webhook.MaxAttempts,
+				BaseDelay:   conf.Webhook.BaseDelay,
+				Timeout:     conf.Webhook.Timeout,
+			}))
+		case channelNtfy:
+			notifiers = append(notifiers, ntfy)
+		default:
+			return nil, nil, fmt.Errorf("unknown notify channel %q", ch)
+		}
+	}
+
+	return notifier.NewMulti(notifiers...), digests, nil
+}
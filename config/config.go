@@ -12,6 +12,22 @@ import (
 type Skyeng struct {
 	User     string `yaml:"user"`
 	Password string
+	// Deadline for a single skyeng api call.
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   Retry         `yaml:"retry"`
+	// File to persist skyeng session between restarts, session is kept in memory if empty.
+	SessionFile string `yaml:"session_file"`
+	// Api endpoints overrides, default skyeng endpoints are used if empty.
+	AuthEndpoint  string `yaml:"auth_endpoint"`
+	WordsEndpoint string `yaml:"words_endpoint"`
+	DictEndpoint  string `yaml:"dict_endpoint"`
+}
+
+// Retry represents retry policy parameters, zero values mean defaults.
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
 }
 
 // Pushover is group for pushover client parameters.
@@ -19,6 +35,69 @@ type Pushover struct {
 	Token  string
 	User   string `yaml:"user"`
 	Device string `yaml:"device"`
+	// Priority from -2 (lowest) to 2 (emergency), emergency pushes repeat until acknowledged.
+	Priority int    `yaml:"priority"`
+	Sound    string `yaml:"sound"`
+	HTML     bool   `yaml:"html"`
+	URL      string `yaml:"url"`
+	URLTitle string `yaml:"url_title"`
+	// Time after which pushes are deleted from devices, pushes are kept if zero.
+	TTL time.Duration `yaml:"ttl"`
+	// Emergency priority retry interval and expiration.
+	Retry  time.Duration `yaml:"retry"`
+	Expire time.Duration `yaml:"expire"`
+	// Attach image of the pushed word.
+	AttachImage bool `yaml:"attach_image"`
+	// Pushes are merged into digest sent once per digest interval when remaining monthly quota is below low quota.
+	LowQuota       int           `yaml:"low_quota"`
+	DigestInterval time.Duration `yaml:"digest_interval"`
+	// Show today's reviewed words count and streak on Pushover widgets.
+	Glances bool `yaml:"glances"`
+	// Count of words to review daily, progress percent is counted from it.
+	DailyGoal int `yaml:"daily_goal"`
+}
+
+// Email represents SMTP notifier parameters.
+type Email struct {
+	// SMTP server address as host:port.
+	Addr     string `yaml:"addr"`
+	Username string `yaml:"username"`
+	Password string
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"`
+	// Pushed words are collected and sent once per digest period, every push is emailed if zero.
+	DigestPeriod time.Duration `yaml:"digest_period"`
+}
+
+// Webhook represents webhook notifier parameters, zero values mean defaults.
+type Webhook struct {
+	Endpoints   []WebhookEndpoint `yaml:"endpoints"`
+	MaxAttempts int               `yaml:"max_attempts"`
+	BaseDelay   time.Duration     `yaml:"base_delay"`
+	Timeout     time.Duration     `yaml:"timeout"`
+}
+
+// WebhookEndpoint represents webhook receiver, WEBHOOK_SECRET is used if secret is empty.
+type WebhookEndpoint struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+}
+
+// Ntfy represents ntfy notifier parameters.
+type Ntfy struct {
+	// Ntfy server URL, https://ntfy.sh is used if empty.
+	Server string `yaml:"server"`
+	Topic  string `yaml:"topic"`
+	Token  string
+	// Priority from 1 (min) to 5 (max).
+	Priority int      `yaml:"priority"`
+	Tags     []string `yaml:"tags"`
+	Click    string   `yaml:"click"`
+	// Public URL of the http server, "I know it" and "Show examples" actions are not added if empty.
+	CallbackURL string `yaml:"callback_url"`
+	// CallbackSecret signs actions, it is taken from NTFY_CALLBACK_SECRET.
+	CallbackSecret string
 }
 
 // Bot represents telegram bot parameters.
@@ -27,6 +106,20 @@ type Bot struct {
 	User  string `yaml:"user"`
 }
 
+// Cache represents skyeng responses cache parameters, zero values mean defaults.
+type Cache struct {
+	WordsetsTTL time.Duration `yaml:"wordsets_ttl"`
+	WordsTTL    time.Duration `yaml:"words_ttl"`
+	MeaningsTTL time.Duration `yaml:"meanings_ttl"`
+	MaxEntries  int           `yaml:"max_entries"`
+}
+
+// Snapshot represents offline snapshot parameters, snapshot is disabled if file path is empty.
+type Snapshot struct {
+	FilePath     string        `yaml:"file_path"`
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
 type YamlStorage struct {
 	FilePath string `yaml:"file_path"`
 }
@@ -37,10 +130,21 @@ type Config struct {
 	Port int `yaml:"http_port"`
 	// Send words interval.
 	SendInterval time.Duration `yaml:"send_interval"` // minutes
-	Bot          Bot           `yaml:"bot"`
-	Skyeng       Skyeng        `yaml:"skyeng"`
-	Pushover     Pushover      `yaml:"pushover"`
-	YamlStorage  YamlStorage   `yaml:"yaml_storage"`
+	// Send schedule overriding interval, like "weekdays 9:00-18:00 every 45m", "daily 08:00" or cron expression.
+	Schedule string `yaml:"schedule"`
+	// Timezone of the schedule, local time is used if empty.
+	Timezone string   `yaml:"timezone"`
+	Bot      Bot      `yaml:"bot"`
+	Skyeng   Skyeng   `yaml:"skyeng"`
+	Cache    Cache    `yaml:"cache"`
+	Snapshot Snapshot `yaml:"snapshot"`
+	// Channels to deliver scheduled pushes to, telegram is used if empty.
+	Notify      []string    `yaml:"notify"`
+	Pushover    Pushover    `yaml:"pushover"`
+	Email       Email       `yaml:"email"`
+	Webhook     Webhook     `yaml:"webhook"`
+	Ntfy        Ntfy        `yaml:"ntfy"`
+	YamlStorage YamlStorage `yaml:"yaml_storage"`
 }
 
 // GetConfig returns config.
@@ -59,5 +163,22 @@ func GetConfig(cfgPath string) (*Config, error) {
 	}
 	config.Skyeng.Password = os.Getenv("SKYENG_PASSWORD")
 	config.Bot.Token = os.Getenv("SKYENG_BOT_TOKEN")
+	if token := os.Getenv("PUSHOVER_TOKEN"); token != "" {
+		config.Pushover.Token = token
+	}
+	if password := os.Getenv("SMTP_PASSWORD"); password != "" {
+		config.Email.Password = password
+	}
+	if token := os.Getenv("NTFY_TOKEN"); token != "" {
+		config.Ntfy.Token = token
+	}
+	config.Ntfy.CallbackSecret = os.Getenv("NTFY_CALLBACK_SECRET")
+	if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
+		for i := range config.Webhook.Endpoints {
+			if config.Webhook.Endpoints[i].Secret == "" {
+				config.Webhook.Endpoints[i].Secret = secret
+			}
+		}
+	}
 	return &config, nil
 }
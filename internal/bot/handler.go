@@ -7,10 +7,14 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
+	"github.com/pachmu/skyeng-push-notificator/internal/schedule"
 	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pachmu/skyeng-push-notificator/internal/srs"
 	"github.com/pachmu/skyeng-push-notificator/internal/state"
 	"github.com/pachmu/skyeng-push-notificator/internal/storage"
 	"github.com/pkg/errors"
@@ -41,20 +45,38 @@ const (
 	actionSuspend        = "/suspend"
 	actionChangeInterval = "/interval"
 	actionStartRandom    = "/start_random"
+	actionSearch         = "/search"
+	actionCreateWordset  = "/create_wordset"
+	actionSkipLearned    = "/skip_learned"
+	actionSchedule       = "/schedule"
+	actionQuiet          = "/quiet"
+	actionDND            = "/dnd"
+	actionSRS            = "/srs"
+	actionResume         = "/resume"
+	actionSendNow        = "/send_now"
+	actionSkipNext       = "/skip_next"
 )
 
+// wordsetsPageSize is count of wordsets shown on one page.
+const wordsetsPageSize = 10
+
 const (
 	callbackNextWordsetPage = "next"
 	callbackPrevWordsetPage = "prev"
 	callbackGetWords        = "get_words"
 	callbackGetWord         = "get_word"
+	callbackReviewWord      = "review_word"
 	callbackSetWordset      = "set_wordset"
 	callbackShowDefinition  = "show_definition"
 	callbackShowExamples    = "show_examples"
+	callbackSaveWord        = "save_word"
+	callbackAddWord         = "add_word"
+	callbackRemoveWord      = "remove_word"
+	callbackGrade           = "grade"
 )
 
-type botActions map[string]func(m *tgbotapi.Message, chatParams []string) (tgbotapi.Chattable, error)
-type botCallbacks map[string]func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error)
+type botActions map[string]func(ctx context.Context, m *tgbotapi.Message, chatParams []string) (tgbotapi.Chattable, error)
+type botCallbacks map[string]func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error)
 
 type bot struct {
 	handler *MessageHandler
@@ -62,7 +84,7 @@ type bot struct {
 }
 
 func (b *bot) Run(ctx context.Context) error {
-	err := b.handler.init(b.bot)
+	err := b.handler.init(ctx, b.bot)
 	if err != nil {
 		return err
 	}
@@ -85,7 +107,7 @@ func (b *bot) Run(ctx context.Context) error {
 		var update tgbotapi.Update
 		select {
 		case update = <-updates:
-			err := b.handler.handle(update)
+			err := b.handler.handle(ctx, update)
 			if err != nil {
 				logrus.Error(err)
 			}
@@ -102,6 +124,7 @@ func NewMessageHandler(user string, client skyeng.Client, state *state.State, st
 		user:         user,
 		state:        state,
 		storage:      storage,
+		ackTimeout:   defaultAckTimeout,
 	}
 }
 
@@ -114,37 +137,276 @@ type MessageHandler struct {
 	storage      storage.Storage
 	skyengClient skyeng.Client
 	callbacks    botCallbacks
-	data         *storage.Data
+	statsHook    StatsHook
+	// ackTimeout limits waiting for sender to apply control commands.
+	ackTimeout time.Duration
+	// data is shared by bot, sender and http server goroutines, it is guarded by mx.
+	data *storage.Data
+	mx   sync.Mutex
 }
 
-func (h *MessageHandler) init(api *tgbotapi.BotAPI) error {
+// getData returns copy of the user data, nil is returned if the bot is not started yet.
+func (h *MessageHandler) getData() *storage.Data {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.data == nil {
+		return nil
+	}
+	return h.data.Copy()
+}
+
+// updateData applies change to the user data and stores it, changes are serialized.
+func (h *MessageHandler) updateData(change func(data *storage.Data)) error {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.data == nil {
+		return errors.New("bot is not started yet")
+	}
+	change(h.data)
+
+	return h.storage.WriteData(h.data)
+}
+
+// StatsHook is called with review statistics updated by the user, now is taken in the user timezone.
+type StatsHook func(ctx context.Context, stats storage.Stats, now time.Time) error
+
+// OnStats sets hook called when user reviews a word.
+func (h *MessageHandler) OnStats(hook StatsHook) {
+	h.statsHook = hook
+}
+
+func (h *MessageHandler) init(ctx context.Context, api *tgbotapi.BotAPI) error {
 	h.api = api
 	h.actions = botActions{
-		actionStart: func(m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+		actionStart: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
 			resp := h.getReplyText(m, "Hello "+m.From.UserName+"! Please choose the action:")
 			h.withActionKeyboard(resp)
 			return resp, nil
 		},
-		actionStartRandom: func(m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
-			resp, err := h.startRandomSending(m.Chat.ID)
+		actionStartRandom: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			resp, err := h.startRandomSending(ctx, m.Chat.ID)
 			if err != nil {
 				return nil, err
 			}
 			return resp, nil
 		},
-		actionGetWordsets: func(m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+		actionGetWordsets: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
 			resp := h.getReplyText(m, "")
-			err := h.showWordsets(resp, 1)
+			err := h.showWordsets(ctx, resp, 1)
 			if err != nil {
 				return nil, err
 			}
 			return resp, nil
 		},
-		actionSuspend: func(m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
-			h.state.SuspendWork()
-			return h.getReplyText(m, "Work suspended!"), nil
+		actionSuspend: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			status, err := h.Suspend(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(m, queuedReply(status, "Work suspended!")), nil
+		},
+		actionResume: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			status, err := h.Resume(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(m, queuedReply(status, "Work resumed!")), nil
 		},
-		actionChangeInterval: func(m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+		actionSendNow: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			status, err := h.SendNow(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(m, queuedReply(status, "Word is on the way!")), nil
+		},
+		actionSkipNext: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			status, err := h.SkipNext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if status.Suspended {
+				return h.getReplyText(m, queuedReply(status, "Next send will be skipped after resume!")), nil
+			}
+			return h.getReplyText(m, queuedReply(status, "Next send will be skipped!")), nil
+		},
+		actionSearch: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			if len(params) == 0 {
+				return nil, errors.New("Search query required")
+			}
+			resp := h.getReplyText(m, "")
+			err := h.showSearch(ctx, resp, strings.Join(params, " "))
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		},
+		actionCreateWordset: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			if len(params) == 0 {
+				return nil, errors.New("Wordset title required")
+			}
+			wordset, err := h.skyengClient.CreateWordset(ctx, strings.Join(params, " "))
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(m, fmt.Sprintf("Wordset %q created!", wordset.Title)), nil
+		},
+		actionSRS: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			enable := true
+			if len(params) > 0 {
+				switch params[0] {
+				case "on":
+				case "off":
+					enable = false
+				default:
+					return nil, errors.Errorf("unknown value %q, use on or off", params[0])
+				}
+			}
+			err := h.updateData(func(data *storage.Data) {
+				data.SRS = enable
+			})
+			if err != nil {
+				return nil, err
+			}
+			if enable {
+				return h.getReplyText(m, "Spaced repetition enabled, the most due word will be sent!"), nil
+			}
+			return h.getReplyText(m, "Spaced repetition disabled!"), nil
+		},
+		actionSkipLearned: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			skip := true
+			if len(params) > 0 {
+				switch params[0] {
+				case "on":
+				case "off":
+					skip = false
+				default:
+					return nil, errors.Errorf("unknown value %q, use on or off", params[0])
+				}
+			}
+			err := h.updateData(func(data *storage.Data) {
+				data.SkipLearned = skip
+			})
+			if err != nil {
+				return nil, err
+			}
+			if skip {
+				return h.getReplyText(m, "Learned words will be skipped!"), nil
+			}
+			return h.getReplyText(m, "Learned words will be sent!"), nil
+		},
+		actionSchedule: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			data := h.getData()
+			if len(params) == 0 {
+				if data.Schedule == "" {
+					return h.getReplyText(m, "Words are sent every interval."), nil
+				}
+				return h.getReplyText(m, fmt.Sprintf("Schedule: %s %s", data.Schedule, data.Timezone)), nil
+			}
+			timezone := data.Timezone
+			if strings.HasPrefix(params[0], "TZ=") {
+				timezone = strings.TrimPrefix(params[0], "TZ=")
+				params = params[1:]
+			}
+			spec := strings.Join(params, " ")
+			status, err := h.setSchedule(spec, timezone)
+			if err != nil {
+				return nil, err
+			}
+			if timezone != data.Timezone {
+				err = h.setQuietHours(data.Quiet, timezone)
+				if err != nil {
+					return nil, err
+				}
+			}
+			err = h.updateData(func(data *storage.Data) {
+				data.Schedule = spec
+				data.Timezone = timezone
+				data.Suspended = false
+			})
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(m, queuedReply(h.acknowledge(ctx, status), "Schedule changed!")), nil
+		},
+		actionQuiet: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			if len(params) == 0 {
+				return nil, errors.New("Quiet hours required, like 22:00-08:00 [weekends window] [defer], or off")
+			}
+			data := h.getData()
+			timezone := data.Timezone
+			var quiet storage.Quiet
+			var windows []string
+			for _, p := range params {
+				switch {
+				case strings.HasPrefix(p, "TZ="):
+					timezone = strings.TrimPrefix(p, "TZ=")
+				case p == "defer":
+					quiet.Defer = true
+				case p == "drop":
+				default:
+					windows = append(windows, p)
+				}
+			}
+			// "off" disables quiet hours, while "off 23:00-10:00" keeps weekends window only.
+			if len(windows) == 1 && windows[0] == "off" {
+				windows = nil
+			}
+			if len(windows) > 2 {
+				return nil, errors.New("Too many windows, expected weekdays and weekends windows")
+			}
+			if len(windows) > 0 {
+				quiet.Weekdays = windows[0]
+			}
+			if len(windows) > 1 {
+				quiet.Weekends = windows[1]
+			}
+			err := h.setQuietHours(quiet, timezone)
+			if err != nil {
+				return nil, err
+			}
+			if timezone != data.Timezone && data.Schedule != "" {
+				_, err = h.setSchedule(data.Schedule, timezone)
+				if err != nil {
+					return nil, err
+				}
+			}
+			err = h.updateData(func(data *storage.Data) {
+				data.Quiet = quiet
+				data.Timezone = timezone
+			})
+			if err != nil {
+				return nil, err
+			}
+			if quiet.Weekdays == "" {
+				return h.getReplyText(m, "Quiet hours disabled!"), nil
+			}
+			return h.getReplyText(m, "Quiet hours changed!"), nil
+		},
+		actionDND: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
+			if len(params) == 0 {
+				return nil, errors.New("Duration required, like 2h, or off")
+			}
+			var until time.Time
+			if params[0] != "off" {
+				d, err := time.ParseDuration(params[0])
+				if err != nil || d <= 0 {
+					return nil, errors.Errorf("failed to parse duration %q", params[0])
+				}
+				until = time.Now().Add(d)
+			}
+			h.state.SetDND(until)
+			err := h.updateData(func(data *storage.Data) {
+				data.DNDUntil = until
+			})
+			if err != nil {
+				return nil, err
+			}
+			if until.IsZero() {
+				return h.getReplyText(m, "Do not disturb disabled!"), nil
+			}
+			return h.getReplyText(m, fmt.Sprintf("Do not disturb until %s!", until.Format("15:04 Jan 2"))), nil
+		},
+		actionChangeInterval: func(ctx context.Context, m *tgbotapi.Message, params []string) (tgbotapi.Chattable, error) {
 			if len(params) == 0 {
 				return nil, errors.New("Interval value required")
 			}
@@ -152,13 +414,16 @@ func (h *MessageHandler) init(api *tgbotapi.BotAPI) error {
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to parse interval value")
 			}
-			h.state.ChangeTimeInterval(time.Duration(interval))
-			h.data.Interval = time.Duration(interval)
-			err = h.storage.WriteData(h.data)
+			err = h.updateData(func(data *storage.Data) {
+				data.Interval = time.Duration(interval)
+				data.Schedule = ""
+				data.Suspended = false
+			})
 			if err != nil {
 				return nil, err
 			}
-			return h.getReplyText(m, "Time interval changed!"), nil
+			status := h.acknowledge(ctx, h.state.ChangeTimeInterval(time.Duration(interval)))
+			return h.getReplyText(m, queuedReply(status, "Time interval changed!")), nil
 		},
 	}
 
@@ -174,13 +439,39 @@ func (h *MessageHandler) init(api *tgbotapi.BotAPI) error {
 		return num, nil
 	}
 
-	navigate := func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+	getWord := func(review bool) func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+		return func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+			if len(args) < 3 {
+				return nil, errors.New("not enough args")
+			}
+			wordsetID, err := argToInt(args, "failed to get wordset id from args")
+			if err != nil {
+				return nil, err
+			}
+			meaningID, err := argToInt(args[1:], "failed to get word by id")
+			if err != nil {
+				return nil, err
+			}
+			wordsetName := strings.Join(args[2:], " ")
+
+			resp, err := h.showWord(ctx, query.Message, wordsetID, wordsetName, meaningID, review)
+			if err != nil {
+				return nil, err
+			}
+			err = h.recordReview(ctx, meaningID)
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+	}
+	navigate := func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
 		page, err := argToInt(args, "failed to get wordsets for undefined page")
 		if err != nil {
 			return nil, err
 		}
 		resp := h.getReplyText(query.Message, "")
-		err = h.showWordsets(resp, page)
+		err = h.showWordsets(ctx, resp, page)
 		if err != nil {
 			return nil, err
 		}
@@ -188,67 +479,122 @@ func (h *MessageHandler) init(api *tgbotapi.BotAPI) error {
 	}
 
 	h.callbacks = botCallbacks{
-		callbackGetWords: func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+		callbackGetWords: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
 			wordsetID, err := argToInt(args, "failed to get words from undefined wordset")
 			if err != nil {
 				return nil, err
 			}
 			resp := h.getReplyText(query.Message, "")
-			err = h.showWords(resp, wordsetID, strings.Join(args[1:], " "))
+			err = h.showWords(ctx, resp, wordsetID, strings.Join(args[1:], " "))
 			if err != nil {
 				return nil, err
 			}
 			return resp, nil
 		},
-		callbackGetWord: func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
-			if len(args) < 3 {
-				return nil, errors.New("not enough args")
+		callbackGetWord:    getWord(false),
+		callbackReviewWord: getWord(true),
+		callbackShowExamples: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+			meaningID, err := argToInt(args, "failed to get meaning by id")
+			if err != nil {
+				return nil, err
 			}
-			wordsetID, err := argToInt(args, "failed to get wordset id from args")
+			resp := h.getReplyText(query.Message, "")
+
+			err = h.showExamples(ctx, resp, meaningID)
 			if err != nil {
 				return nil, err
 			}
-			meaningID, err := argToInt(args[1:], "failed to get word by id")
+			return resp, nil
+		},
+		callbackShowDefinition: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+			meaningID, err := argToInt(args, "failed to get meaning by id")
 			if err != nil {
 				return nil, err
 			}
-			wordsetName := strings.Join(args[2:], " ")
+			resp := h.getReplyText(query.Message, "")
 
-			resp, err := h.showWord(query.Message, wordsetID, wordsetName, meaningID)
+			err = h.showDefinition(ctx, resp, meaningID)
 			if err != nil {
 				return nil, err
 			}
 			return resp, nil
 		},
-		callbackShowExamples: func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+		callbackSaveWord: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
 			meaningID, err := argToInt(args, "failed to get meaning by id")
 			if err != nil {
 				return nil, err
 			}
+			page := 1
+			if len(args) > 1 {
+				page, err = argToInt(args[1:], "failed to get wordsets for undefined page")
+				if err != nil {
+					return nil, err
+				}
+			}
 			resp := h.getReplyText(query.Message, "")
-
-			err = h.showExamples(resp, meaningID)
+			err = h.showSaveWordsets(ctx, resp, meaningID, page)
 			if err != nil {
 				return nil, err
 			}
 			return resp, nil
 		},
-		callbackShowDefinition: func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+		callbackAddWord: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+			if len(args) < 2 {
+				return nil, errors.New("not enough args")
+			}
+			wordsetID, err := argToInt(args, "failed to get wordset id from args")
+			if err != nil {
+				return nil, err
+			}
+			meaningID, err := argToInt(args[1:], "failed to get meaning by id")
+			if err != nil {
+				return nil, err
+			}
+			err = h.skyengClient.AddMeaning(ctx, wordsetID, meaningID)
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(query.Message, "Word saved!"), nil
+		},
+		callbackRemoveWord: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+			if len(args) < 2 {
+				return nil, errors.New("not enough args")
+			}
+			wordsetID, err := argToInt(args, "failed to get wordset id from args")
+			if err != nil {
+				return nil, err
+			}
+			meaningID, err := argToInt(args[1:], "failed to get meaning by id")
+			if err != nil {
+				return nil, err
+			}
+			err = h.skyengClient.RemoveMeaning(ctx, wordsetID, meaningID)
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(query.Message, "Word removed!"), nil
+		},
+		callbackGrade: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+			if len(args) < 2 {
+				return nil, errors.New("not enough args")
+			}
 			meaningID, err := argToInt(args, "failed to get meaning by id")
 			if err != nil {
 				return nil, err
 			}
-			resp := h.getReplyText(query.Message, "")
-
-			err = h.showDefinition(resp, meaningID)
+			grade, err := srs.ParseGrade(args[1])
 			if err != nil {
 				return nil, err
 			}
-			return resp, nil
+			item, err := h.grade(meaningID, grade)
+			if err != nil {
+				return nil, err
+			}
+			return h.getReplyText(query.Message, fmt.Sprintf("Next review in %s.", formatInterval(item.Interval))), nil
 		},
 		callbackNextWordsetPage: navigate,
 		callbackPrevWordsetPage: navigate,
-		callbackSetWordset: func(query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
+		callbackSetWordset: func(ctx context.Context, query *tgbotapi.CallbackQuery, args []string) (tgbotapi.Chattable, error) {
 			wordsetID, err := argToInt(args, "failed to get wordset ID from undefined args")
 			if err != nil {
 				return nil, err
@@ -257,7 +603,7 @@ func (h *MessageHandler) init(api *tgbotapi.BotAPI) error {
 				return nil, errors.New("not enough args")
 			}
 			wordsetName := strings.Join(args[1:], "")
-			resp, err := h.startWordsetSending(query.Message.Chat.ID, wordsetID, wordsetName)
+			resp, err := h.startWordsetSending(ctx, query.Message.Chat.ID, wordsetID, wordsetName)
 			if err != nil {
 				return nil, err
 			}
@@ -265,14 +611,14 @@ func (h *MessageHandler) init(api *tgbotapi.BotAPI) error {
 		},
 	}
 
-	err := h.setupState()
+	err := h.setupState(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (h *MessageHandler) handle(upd tgbotapi.Update) error {
+func (h *MessageHandler) handle(ctx context.Context, upd tgbotapi.Update) error {
 	defer func() {
 		if err := recover(); err != nil {
 			logrus.Error(err, string(debug.Stack()))
@@ -293,9 +639,9 @@ func (h *MessageHandler) handle(upd tgbotapi.Update) error {
 	var resp tgbotapi.Chattable
 	switch {
 	case upd.Message != nil:
-		resp, err = h.handleActions(upd.Message)
+		resp, err = h.handleActions(ctx, upd.Message)
 	case upd.CallbackQuery != nil:
-		resp, err = h.handleCallback(upd.CallbackQuery)
+		resp, err = h.handleCallback(ctx, upd.CallbackQuery)
 	default:
 		return nil
 	}
@@ -340,18 +686,23 @@ func (h *MessageHandler) withActionKeyboard(resp *tgbotapi.MessageConfig) {
 	)
 }
 
-func (h *MessageHandler) handleActions(msg *tgbotapi.Message) (tgbotapi.Chattable, error) {
+func (h *MessageHandler) handleActions(ctx context.Context, msg *tgbotapi.Message) (tgbotapi.Chattable, error) {
 	logrus.Infof("Message [%+v]", msg)
 	words := strings.Split(msg.Text, " ")
 	if len(words) == 0 {
 		return h.getReplyText(msg, "Unknown command"), nil
 	}
 	cmd, ok := h.actions[words[0]]
+	if !ok && words[0] != "" && !strings.HasPrefix(words[0], "/") {
+		// Free text is looked up in the dictionary.
+		cmd, ok = h.actions[actionSearch], true
+		words = append([]string{actionSearch}, words...)
+	}
 	if !ok {
 		return h.getReplyText(msg, "Unknown command"), nil
 	}
 
-	resp, err := cmd(msg, words[1:])
+	resp, err := cmd(ctx, msg, words[1:])
 	if err != nil {
 		return nil, err
 	}
@@ -359,7 +710,7 @@ func (h *MessageHandler) handleActions(msg *tgbotapi.Message) (tgbotapi.Chattabl
 	return resp, nil
 }
 
-func (h *MessageHandler) handleCallback(query *tgbotapi.CallbackQuery) (tgbotapi.Chattable, error) {
+func (h *MessageHandler) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) (tgbotapi.Chattable, error) {
 	logrus.Infof("Callback [%+v]", query.Data)
 	if len(query.Data) == 0 {
 		return nil, errors.WithStack(errors.New("failed to execute callback, data is empty"))
@@ -373,7 +724,7 @@ func (h *MessageHandler) handleCallback(query *tgbotapi.CallbackQuery) (tgbotapi
 		return h.getReplyText(query.Message, "Unknown callback"), nil
 	}
 
-	resp, err := callback(query, args[1:])
+	resp, err := callback(ctx, query, args[1:])
 	if err != nil {
 		return nil, err
 	}
@@ -389,23 +740,16 @@ func (h *MessageHandler) handleCallback(query *tgbotapi.CallbackQuery) (tgbotapi
 	return resp, nil
 }
 
-func (h *MessageHandler) startWordsetSending(chatID int64, wordsetID int, wordsetName string) (tgbotapi.Chattable, error) {
-	h.state.SetWordsetCallback(func() error {
-		resp := tgbotapi.NewMessage(chatID, "")
-		err := h.showWords(&resp, wordsetID, wordsetName)
-		if err != nil {
-			return err
-		}
-		_, err = h.api.Send(resp)
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		return nil
+func (h *MessageHandler) startWordsetSending(ctx context.Context, chatID int64, wordsetID int, wordsetName string) (tgbotapi.Chattable, error) {
+	h.state.SetWordsetCallback(func(ctx context.Context) (*notifier.Card, error) {
+		return h.sendingCard(ctx, wordsetID, wordsetName)
+	})
+	err := h.updateData(func(data *storage.Data) {
+		data.Random = false
+		data.WordsetID = wordsetID
+		data.WordsetName = wordsetName
+		data.ChatID = chatID
 	})
-	h.data.WordsetID = wordsetID
-	h.data.WordsetName = wordsetName
-	h.data.ChatID = chatID
-	err := h.storage.WriteData(h.data)
 	if err != nil {
 		return nil, err
 	}
@@ -413,15 +757,29 @@ func (h *MessageHandler) startWordsetSending(chatID int64, wordsetID int, wordse
 	return &resp, nil
 }
 
-func (h *MessageHandler) startRandomSending(chatID int64) (tgbotapi.Chattable, error) {
-	f, err := h.getRandomPeriodicSenderCallback(chatID)
+// SetWordset starts periodic sending of the wordset to the known chat.
+func (h *MessageHandler) SetWordset(ctx context.Context, wordsetID int, wordsetName string) error {
+	data := h.getData()
+	if data == nil || data.ChatID == 0 {
+		return errors.New("chat is unknown, start the bot first")
+	}
+	_, err := h.startWordsetSending(ctx, data.ChatID, wordsetID, wordsetName)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *MessageHandler) startRandomSending(ctx context.Context, chatID int64) (tgbotapi.Chattable, error) {
+	f, err := h.getRandomPeriodicSenderCallback(ctx)
 	if err != nil {
 		return nil, err
 	}
 	h.state.SetWordsetCallback(f)
-	h.data.Random = true
-	h.data.ChatID = chatID
-	err = h.storage.WriteData(h.data)
+	err = h.updateData(func(data *storage.Data) {
+		data.Random = true
+		data.ChatID = chatID
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -429,45 +787,34 @@ func (h *MessageHandler) startRandomSending(chatID int64) (tgbotapi.Chattable, e
 	return &resp, nil
 }
 
-func (h *MessageHandler) getRandomPeriodicSenderCallback(chatID int64) (func() error, error) {
-	wordsets, err := h.skyengClient.GetWordsets(0)
+func (h *MessageHandler) getRandomPeriodicSenderCallback(ctx context.Context) (func(ctx context.Context) (*notifier.Card, error), error) {
+	wordsets, err := skyeng.AllWordsets(ctx, h.skyengClient).Collect()
 	if err != nil {
 		return nil, err
 	}
+	if len(wordsets) == 0 {
+		return nil, errors.New("no wordsets found")
+	}
 	rand.Seed(time.Now().Unix())
 	getRandWordsetID := func() (int, string) {
 		num := rand.Intn(len(wordsets))
 		return wordsets[num].ID, wordsets[num].Title
 	}
-	return func() error {
+	return func(ctx context.Context) (*notifier.Card, error) {
 		wordsetID, name := getRandWordsetID()
-		wordsetResp := tgbotapi.NewMessage(chatID, "")
-		err := h.showWords(&wordsetResp, wordsetID, name)
-		if err != nil {
-			return err
-		}
-		_, err = h.api.Send(wordsetResp)
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		return nil
+		return h.sendingCard(ctx, wordsetID, name)
 	}, nil
 }
 
-func (h *MessageHandler) showWordsets(resp *tgbotapi.MessageConfig, page int) error {
-	wordsets, err := h.skyengClient.GetWordsets(page)
+func (h *MessageHandler) showWordsets(ctx context.Context, resp *tgbotapi.MessageConfig, page int) error {
+	wordsets, err := h.skyengClient.GetWordsets(ctx, page, wordsetsPageSize)
 	if err != nil {
 		return err
 	}
 	prevPage := page - 1
-	var nextPage int
-	if len(wordsets) == 0 {
-		nextPage = page
-	} else {
-		nextPage = page + 1
-	}
+	nextPage := page + 1
 	var buttons [][]tgbotapi.InlineKeyboardButton
-	for _, ws := range wordsets {
+	for _, ws := range wordsets.Data {
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
 			tgbotapi.NewInlineKeyboardButtonData(ws.Title, fmt.Sprintf("get_words %d %s", ws.ID, ws.Title)),
 		})
@@ -479,22 +826,62 @@ func (h *MessageHandler) showWordsets(resp *tgbotapi.MessageConfig, page int) er
 			tgbotapi.NewInlineKeyboardButtonData(" ⬅️", fmt.Sprintf("prev %d", prevPage)),
 		)
 	}
-	navigation = append(
-		navigation,
-		tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("next %d", nextPage)),
-	)
-	tgbotapi.NewInlineKeyboardRow()
-	buttons = append(buttons, navigation)
+	if !wordsets.IsLastPage() {
+		navigation = append(
+			navigation,
+			tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("next %d", nextPage)),
+		)
+	}
+	if len(navigation) > 0 {
+		buttons = append(buttons, navigation)
+	}
 	resp.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
 	resp.Text = "Choose wordset for more actions."
 
 	return nil
 }
 
-func (h *MessageHandler) showWords(resp *tgbotapi.MessageConfig, wordsetID int, wordsetName string) error {
+func (h *MessageHandler) showSaveWordsets(ctx context.Context, resp *tgbotapi.MessageConfig, meaningID int, page int) error {
+	wordsets, err := h.skyengClient.GetWordsets(ctx, page, wordsetsPageSize)
+	if err != nil {
+		return err
+	}
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, ws := range wordsets.Data {
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(ws.Title, fmt.Sprintf("%s %d %d", callbackAddWord, ws.ID, meaningID)),
+		})
+	}
+	var navigation []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		navigation = append(
+			navigation,
+			tgbotapi.NewInlineKeyboardButtonData(" ⬅️", fmt.Sprintf("%s %d %d", callbackSaveWord, meaningID, page-1)),
+		)
+	}
+	if !wordsets.IsLastPage() {
+		navigation = append(
+			navigation,
+			tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("%s %d %d", callbackSaveWord, meaningID, page+1)),
+		)
+	}
+	if len(navigation) > 0 {
+		buttons = append(buttons, navigation)
+	}
+	if len(buttons) == 0 {
+		resp.Text = "There are no wordsets, create one with " + actionCreateWordset
+		return nil
+	}
+	resp.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	resp.Text = "Choose wordset to save the word."
+
+	return nil
+}
+
+func (h *MessageHandler) showWords(ctx context.Context, resp *tgbotapi.MessageConfig, wordsetID int, wordsetName string) error {
 	resp.Text = "Choose word to show translation and examples."
 	resp.ParseMode = tgbotapi.ModeHTML
-	buttons, err := h.getWordsMarkup(wordsetID, wordsetName, 0, nil)
+	buttons, err := h.getWordsMarkup(ctx, wordsetID, wordsetName, 0, nil)
 	if err != nil {
 		return err
 	}
@@ -504,47 +891,191 @@ func (h *MessageHandler) showWords(resp *tgbotapi.MessageConfig, wordsetID int,
 
 }
 
-func (h *MessageHandler) getWordsMarkup(wordsetID int, wordsetName string, meaningID int, buttons [][]tgbotapi.InlineKeyboardButton) ([][]tgbotapi.InlineKeyboardButton, error) {
-	words, err := h.skyengClient.GetWords(skyeng.Wordset{ID: wordsetID})
+func (h *MessageHandler) getWordsMarkup(ctx context.Context, wordsetID int, wordsetName string, meaningID int, buttons [][]tgbotapi.InlineKeyboardButton) ([][]tgbotapi.InlineKeyboardButton, error) {
+	card, err := h.wordsetCard(ctx, wordsetID, wordsetName)
 	if err != nil {
 		return nil, err
 	}
-	meanings, err := h.skyengClient.GetMeaning(words...)
+
+	return wordsButtons(card, meaningID, buttons), nil
+}
+
+// sendingCard returns card to push, in spaced repetition mode card contains the most due word only.
+func (h *MessageHandler) sendingCard(ctx context.Context, wordsetID int, wordsetName string) (*notifier.Card, error) {
+	data := h.getData()
+	if !data.SRS {
+		return h.wordsetCard(ctx, wordsetID, wordsetName)
+	}
+	card := &notifier.Card{
+		WordsetID:   wordsetID,
+		WordsetName: wordsetName,
+		Review:      true,
+	}
+	words, err := h.wordsetWords(ctx, wordsetID)
 	if err != nil {
 		return nil, err
 	}
+	ids := make([]int, 0, len(words))
+	for _, w := range words {
+		ids = append(ids, w.MeaningID)
+	}
+	meaningID, ok := srs.MostDue(data.Reviews, ids, time.Now())
+	if !ok {
+		return card, nil
+	}
+	meanings, err := h.skyengClient.GetMeaning(ctx, skyeng.Word{MeaningID: meaningID})
+	if err != nil {
+		return nil, err
+	}
+	card.Meanings = meanings.Data
+
+	return card, nil
+}
+
+// wordsetWords returns words of the wordset to push, known and optionally learned words are skipped.
+func (h *MessageHandler) wordsetWords(ctx context.Context, wordsetID int) ([]skyeng.Word, error) {
+	words, err := h.skyengClient.GetWords(ctx, skyeng.Wordset{ID: wordsetID})
+	if err != nil {
+		return nil, err
+	}
+	// Words may be shared by the caching client, they are filtered into a new slice.
+	data := h.getData()
+	filtered := withoutKnown(data.Known, words.Data)
+	if data.SkipLearned {
+		filtered, err = h.withoutLearned(ctx, filtered)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filtered, nil
+}
+
+// wordsetCard returns card with meanings of the wordset words.
+func (h *MessageHandler) wordsetCard(ctx context.Context, wordsetID int, wordsetName string) (*notifier.Card, error) {
+	card := &notifier.Card{
+		WordsetID:   wordsetID,
+		WordsetName: wordsetName,
+	}
+	words, err := h.wordsetWords(ctx, wordsetID)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return card, nil
+	}
+	meanings, err := h.skyengClient.GetMeaning(ctx, words...)
+	if err != nil {
+		return nil, err
+	}
+	if len(meanings.Missing) > 0 {
+		logrus.Warnf("meanings %v of wordset %d not found", meanings.Missing, wordsetID)
+	}
+	card.Meanings = meanings.Data
+
+	return card, nil
+}
+
+// wordsButtons returns keyboard of the card words, buttons replace the word with meaningID.
+func wordsButtons(card *notifier.Card, meaningID int, buttons [][]tgbotapi.InlineKeyboardButton) [][]tgbotapi.InlineKeyboardButton {
 	var wordsButtons [][]tgbotapi.InlineKeyboardButton
-	for _, m := range meanings {
+	for _, m := range card.Meanings {
 		if m.ID == meaningID {
 			wordsButtons = append(wordsButtons, buttons...)
 			continue
 		}
+		getWord := callbackGetWord
+		if card.Review {
+			getWord = callbackReviewWord
+		}
 		wordsButtons = append(wordsButtons, []tgbotapi.InlineKeyboardButton{
-			tgbotapi.NewInlineKeyboardButtonData(m.Text, fmt.Sprintf("%s %d %d %s", callbackGetWord, wordsetID, m.ID, wordsetName)),
+			tgbotapi.NewInlineKeyboardButtonData(m.Text, fmt.Sprintf("%s %d %d %s", getWord, card.WordsetID, m.ID, card.WordsetName)),
 		})
 	}
+	if card.Review && len(card.Meanings) == 1 {
+		var grades []tgbotapi.InlineKeyboardButton
+		for _, g := range srs.Grades {
+			grades = append(grades, tgbotapi.NewInlineKeyboardButtonData(
+				g.Title(), fmt.Sprintf("%s %d %s", callbackGrade, card.Meanings[0].ID, g),
+			))
+		}
+		wordsButtons = append(wordsButtons, grades)
+	}
 	wordsButtons = append(wordsButtons, []tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardButtonData(
-			"Choose wordset", fmt.Sprintf("%s %d %s", callbackSetWordset, wordsetID, wordsetName),
+			"Choose wordset", fmt.Sprintf("%s %d %s", callbackSetWordset, card.WordsetID, card.WordsetName),
 		),
 	})
 
-	return wordsButtons, nil
+	return wordsButtons
 }
 
-func (h *MessageHandler) showWord(message *tgbotapi.Message, wordsetID int, wordsetName string, meaningID int) (*tgbotapi.EditMessageReplyMarkupConfig, error) {
-	meanings, err := h.skyengClient.GetMeaning(skyeng.Word{
-		MeaningID: meaningID,
+// withoutKnown filters out words user marked as known.
+func withoutKnown(knownIDs []int, words []skyeng.Word) []skyeng.Word {
+	if len(knownIDs) == 0 {
+		return words
+	}
+	known := make(map[int]bool, len(knownIDs))
+	for _, id := range knownIDs {
+		known[id] = true
+	}
+	var filtered []skyeng.Word
+	for _, w := range words {
+		if !known[w.MeaningID] {
+			filtered = append(filtered, w)
+		}
+	}
+
+	return filtered
+}
+
+// MarkKnown records the meaning as known and counts it as reviewed, known meanings are not pushed anymore.
+func (h *MessageHandler) MarkKnown(ctx context.Context, meaningID int) error {
+	return h.review(ctx, meaningID, func(data *storage.Data) bool {
+		for _, id := range data.Known {
+			if id == meaningID {
+				return false
+			}
+		}
+		data.Known = append(data.Known, meaningID)
+		return true
 	})
+}
+
+// withoutLearned filters out words learned according to skyeng.
+func (h *MessageHandler) withoutLearned(ctx context.Context, words []skyeng.Word) ([]skyeng.Word, error) {
+	if len(words) == 0 {
+		return words, nil
+	}
+	progress, err := h.skyengClient.GetProgress(ctx, words...)
 	if err != nil {
 		return nil, err
 	}
-	if len(meanings) == 0 {
-		return nil, errors.WithStack(errors.New("failed to get word meaning"))
+	learned := make(map[int]bool, len(progress))
+	for _, p := range progress {
+		learned[p.MeaningID] = p.IsLearned
+	}
+	var filtered []skyeng.Word
+	for _, w := range words {
+		if !learned[w.MeaningID] {
+			filtered = append(filtered, w)
+		}
+	}
+
+	return filtered, nil
+}
+
+// showWord reveals the word in the message keyboard, review message keeps the word only with grade buttons.
+func (h *MessageHandler) showWord(ctx context.Context, message *tgbotapi.Message, wordsetID int, wordsetName string, meaningID int, review bool) (*tgbotapi.EditMessageReplyMarkupConfig, error) {
+	meanings, err := h.skyengClient.GetMeaning(ctx, skyeng.Word{
+		MeaningID: meaningID,
+	})
+	if err != nil {
+		return nil, err
 	}
 	builder := strings.Builder{}
-	for _, m := range meanings {
-		builder.WriteString(fmt.Sprintf("%s [%s] %s", m.Text, m.Transcription, m.Translation))
+	for _, m := range meanings.Data {
+		builder.WriteString(fmt.Sprintf("%s [%s] %s", m.Text, m.Transcription, m.Translation.Text))
 	}
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
@@ -560,26 +1091,117 @@ func (h *MessageHandler) showWord(message *tgbotapi.Message, wordsetID int, word
 			"Show examples", fmt.Sprintf("%s %d", callbackShowExamples, meaningID),
 		),
 	})
-	buttons, err = h.getWordsMarkup(wordsetID, wordsetName, meaningID, buttons)
-	if err != nil {
-		return nil, err
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(
+			"Save to wordset", fmt.Sprintf("%s %d", callbackSaveWord, meaningID),
+		),
+		tgbotapi.NewInlineKeyboardButtonData(
+			"Remove from wordset", fmt.Sprintf("%s %d %d", callbackRemoveWord, wordsetID, meaningID),
+		),
+	})
+	if review {
+		buttons = wordsButtons(&notifier.Card{
+			WordsetID:   wordsetID,
+			WordsetName: wordsetName,
+			Meanings:    meanings.Data,
+			Review:      true,
+		}, meaningID, buttons)
+	} else {
+		buttons, err = h.getWordsMarkup(ctx, wordsetID, wordsetName, meaningID, buttons)
+		if err != nil {
+			return nil, err
+		}
 	}
 	resp := tgbotapi.NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID, tgbotapi.NewInlineKeyboardMarkup(buttons...))
 	return &resp, nil
 }
 
-func (h *MessageHandler) showDefinition(resp *tgbotapi.MessageConfig, meaningID int) error {
-	meanings, err := h.skyengClient.GetMeaning(skyeng.Word{
-		MeaningID: meaningID,
+// grade records recall grade of the meaning, the meaning is scheduled for the next review.
+// Review stats are not updated since the word is counted when it is shown.
+func (h *MessageHandler) grade(meaningID int, grade srs.Grade) (srs.Item, error) {
+	var item srs.Item
+	err := h.updateData(func(data *storage.Data) {
+		if data.Reviews == nil {
+			data.Reviews = make(map[int]srs.Item)
+		}
+		item = data.Reviews[meaningID]
+		item.Review(grade, time.Now())
+		data.Reviews[meaningID] = item
+	})
+	if err != nil {
+		return item, err
+	}
+
+	return item, nil
+}
+
+// formatInterval returns review interval rounded to minutes or days.
+func formatInterval(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Round(time.Minute).String()
+	}
+	days := int(d.Round(24*time.Hour) / (24 * time.Hour))
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+// userNow returns current time in the user timezone, local time is used if timezone is unknown.
+func userNow(timezone string) time.Time {
+	loc, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		logrus.Error(err)
+		return time.Now()
+	}
+
+	return time.Now().In(loc)
+}
+
+// recordReview updates review statistics, days start in the user timezone.
+func (h *MessageHandler) recordReview(ctx context.Context, meaningID int) error {
+	return h.review(ctx, meaningID, func(data *storage.Data) bool {
+		return true
+	})
+}
+
+// review applies the change and counts the meaning review if it is reported by the change.
+// Each meaning is counted once per day, stats hook failure does not fail the review.
+func (h *MessageHandler) review(ctx context.Context, meaningID int, change func(data *storage.Data) bool) error {
+	var reviewed bool
+	var stats storage.Stats
+	var now time.Time
+	err := h.updateData(func(data *storage.Data) {
+		if !change(data) {
+			return
+		}
+		now = userNow(data.Timezone)
+		reviewed = data.Stats.Review(now, meaningID)
+		stats = data.Stats
+		stats.Meanings = append([]int(nil), data.Stats.Meanings...)
 	})
 	if err != nil {
 		return err
 	}
-	if len(meanings) == 0 {
-		return errors.WithStack(errors.New("failed to get word meaning"))
+	if reviewed && h.statsHook != nil {
+		err = h.statsHook(ctx, stats, now)
+		if err != nil {
+			logrus.Error("failed to publish review stats, got ", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *MessageHandler) showDefinition(ctx context.Context, resp *tgbotapi.MessageConfig, meaningID int) error {
+	meanings, err := h.skyengClient.GetMeaning(ctx, skyeng.Word{
+		MeaningID: meaningID,
+	})
+	if err != nil {
+		return err
 	}
 	builder := strings.Builder{}
-	for _, m := range meanings {
+	for _, m := range meanings.Data {
 		builder.WriteString(fmt.Sprintf("%s\n %s", m.Text, m.Definition.Text))
 	}
 	resp.Text = builder.String()
@@ -587,18 +1209,15 @@ func (h *MessageHandler) showDefinition(resp *tgbotapi.MessageConfig, meaningID
 	return nil
 }
 
-func (h *MessageHandler) showExamples(resp *tgbotapi.MessageConfig, meaningID int) error {
-	meanings, err := h.skyengClient.GetMeaning(skyeng.Word{
+func (h *MessageHandler) showExamples(ctx context.Context, resp *tgbotapi.MessageConfig, meaningID int) error {
+	meanings, err := h.skyengClient.GetMeaning(ctx, skyeng.Word{
 		MeaningID: meaningID,
 	})
 	if err != nil {
 		return err
 	}
-	if len(meanings) == 0 {
-		return errors.WithStack(errors.New("failed to get word meaning"))
-	}
 	builder := strings.Builder{}
-	for _, m := range meanings {
+	for _, m := range meanings.Data {
 		builder.WriteString(fmt.Sprintf("%s\n", m.Text))
 		for _, e := range m.Examples {
 			builder.WriteString(fmt.Sprintf("%s\n", e.Text))
@@ -609,6 +1228,41 @@ func (h *MessageHandler) showExamples(resp *tgbotapi.MessageConfig, meaningID in
 	return nil
 }
 
+func (h *MessageHandler) showSearch(ctx context.Context, resp *tgbotapi.MessageConfig, query string) error {
+	results, err := h.skyengClient.Search(ctx, query)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		resp.Text = fmt.Sprintf("Nothing found for %q.", query)
+		return nil
+	}
+	builder := strings.Builder{}
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, r := range results {
+		for _, m := range r.Meanings {
+			builder.WriteString(fmt.Sprintf("%s [%s] %s\n", r.Text, m.Transcription, m.Translation.Text))
+			buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s: definition", m.Translation.Text), fmt.Sprintf("%s %d", callbackShowDefinition, m.ID),
+				),
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s: examples", m.Translation.Text), fmt.Sprintf("%s %d", callbackShowExamples, m.ID),
+				),
+				tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("%s: save", m.Translation.Text), fmt.Sprintf("%s %d", callbackSaveWord, m.ID),
+				),
+			})
+		}
+	}
+	resp.Text = builder.String()
+	if len(buttons) > 0 {
+		resp.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	}
+
+	return nil
+}
+
 func (h *MessageHandler) authorize(user string) error {
 	if user == "" || user != h.user {
 		return fmt.Errorf("I don't know you, %s", user)
@@ -617,23 +1271,146 @@ func (h *MessageHandler) authorize(user string) error {
 	return nil
 }
 
-func (h *MessageHandler) setupState() error {
+// Suspend suspends sending, sending stays suspended after restart.
+func (h *MessageHandler) Suspend(ctx context.Context) (state.Status, error) {
+	status, err := h.setSuspended(h.state.SuspendWork)
+	if err != nil {
+		return status, err
+	}
+
+	return h.acknowledge(ctx, status), nil
+}
+
+// Resume resumes suspended sending by the current schedule.
+func (h *MessageHandler) Resume(ctx context.Context) (state.Status, error) {
+	status, err := h.setSuspended(h.state.Resume)
+	if err != nil {
+		return status, err
+	}
+
+	return h.acknowledge(ctx, status), nil
+}
+
+// setSuspended runs the command along with the data update, so the stored flag follows the command order.
+func (h *MessageHandler) setSuspended(command func() state.Status) (state.Status, error) {
+	var status state.Status
+	err := h.updateData(func(data *storage.Data) {
+		status = command()
+		data.Suspended = status.Suspended
+	})
+	if err != nil {
+		return status, err
+	}
+
+	return status, nil
+}
+
+// SendNow sends card of the current source immediately, quiet hours and do not disturb are ignored.
+func (h *MessageHandler) SendNow(ctx context.Context) (state.Status, error) {
+	return h.acknowledge(ctx, h.state.TriggerNow()), nil
+}
+
+// SkipNext drops the next scheduled send.
+func (h *MessageHandler) SkipNext(ctx context.Context) (state.Status, error) {
+	return h.acknowledge(ctx, h.state.SkipNext()), nil
+}
+
+// defaultAckTimeout limits waiting for sender, the command stays queued after the timeout.
+const defaultAckTimeout = 3 * time.Second
+
+// acknowledge waits for sender to apply the queued command, Applied is set if it is applied in time.
+func (h *MessageHandler) acknowledge(ctx context.Context, status state.Status) state.Status {
+	if !status.Queued {
+		return status
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.ackTimeout)
+	defer cancel()
+	err := h.state.WaitApplied(ctx, status.ID)
+	if err != nil {
+		logrus.Warnf("Sending command %d is not applied yet, got %v", status.ID, err)
+		return status
+	}
+	status.Applied = true
+
+	return status
+}
+
+// queuedReply returns reply to the sending command, the command not applied in time is reported as queued.
+func queuedReply(status state.Status, text string) string {
+	if !status.Queued {
+		return "Too many commands are pending, try later!"
+	}
+	if !status.Applied {
+		return fmt.Sprintf("%s The change is queued, %d commands are pending.", text, status.Pending)
+	}
+	return text
+}
+
+// setSchedule parses schedule spec in the timezone and applies it to the state.
+func (h *MessageHandler) setSchedule(spec string, timezone string) (state.Status, error) {
+	loc, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		return state.Status{}, err
+	}
+	sched, err := schedule.Parse(spec, loc)
+	if err != nil {
+		return state.Status{}, err
+	}
+
+	return h.state.SetSchedule(sched), nil
+}
+
+// setQuietHours parses quiet hours in the timezone and applies them to the state.
+func (h *MessageHandler) setQuietHours(quiet storage.Quiet, timezone string) error {
+	if quiet.Weekdays == "" {
+		h.state.SetQuietHours(nil, false)
+		return nil
+	}
+	loc, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+	q, err := schedule.ParseQuietHours(quiet.Weekdays, quiet.Weekends, loc)
+	if err != nil {
+		return err
+	}
+	h.state.SetQuietHours(q, quiet.Defer)
+
+	return nil
+}
+
+func (h *MessageHandler) setupState(ctx context.Context) error {
 	data, err := h.storage.GetData()
 	if err != nil {
 		return err
 	}
-	h.data = data
-	if data.Interval != 0 {
+	h.mx.Lock()
+	h.data = data.Copy()
+	h.mx.Unlock()
+	if data.Schedule != "" {
+		_, err = h.setSchedule(data.Schedule, data.Timezone)
+		if err != nil {
+			return err
+		}
+	} else if data.Interval != 0 {
 		h.state.ChangeTimeInterval(data.Interval)
 	}
+	err = h.setQuietHours(data.Quiet, data.Timezone)
+	if err != nil {
+		return err
+	}
+	h.state.SetDND(data.DNDUntil)
+	if data.Suspended {
+		h.state.SuspendWork()
+	}
 	if data.Random {
-		_, err = h.startRandomSending(data.ChatID)
+		_, err = h.startRandomSending(ctx, data.ChatID)
 		if err != nil {
 			return err
 		}
 		return nil
 	} else if data.WordsetID != 0 {
-		_, err = h.startWordsetSending(data.ChatID, data.WordsetID, data.WordsetName)
+		_, err = h.startWordsetSending(ctx, data.ChatID, data.WordsetID, data.WordsetName)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,575 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng/skyengtest"
+	"github.com/pachmu/skyeng-push-notificator/internal/srs"
+	"github.com/pachmu/skyeng-push-notificator/internal/state"
+	"github.com/pachmu/skyeng-push-notificator/internal/storage"
+)
+
+const testUser = "tester"
+
+type memoryStorage struct {
+	data storage.Data
+}
+
+func (s *memoryStorage) GetData() (*storage.Data, error) {
+	data := s.data
+	return &data, nil
+}
+
+func (s *memoryStorage) WriteData(data *storage.Data) error {
+	s.data = *data
+	return nil
+}
+
+type sentRequest struct {
+	method string
+	params url.Values
+}
+
+// fakeTelegram is telegram bot api recording sent requests.
+type fakeTelegram struct {
+	*httptest.Server
+	mx   sync.Mutex
+	sent []sentRequest
+}
+
+func newFakeTelegram() *fakeTelegram {
+	tg := &fakeTelegram{}
+	tg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		_ = req.ParseForm()
+		var result interface{} = true
+		switch method {
+		case "getMe":
+			result = tgbotapi.User{ID: 1, UserName: "skyeng_bot", IsBot: true}
+		case "sendMessage":
+			result = tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}}
+		}
+		tg.mx.Lock()
+		tg.sent = append(tg.sent, sentRequest{method: method, params: req.PostForm})
+		tg.mx.Unlock()
+		raw, _ := json.Marshal(result)
+		_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+	}))
+
+	return tg
+}
+
+func (tg *fakeTelegram) last(method string) (url.Values, bool) {
+	tg.mx.Lock()
+	defer tg.mx.Unlock()
+	for i := len(tg.sent) - 1; i >= 0; i-- {
+		if tg.sent[i].method == method {
+			return tg.sent[i].params, true
+		}
+	}
+
+	return nil, false
+}
+
+type testEnv struct {
+	skyeng   *skyengtest.Server
+	telegram *fakeTelegram
+	storage  *memoryStorage
+	handler  *MessageHandler
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{
+		skyeng:   skyengtest.NewServer("user", "pass"),
+		telegram: newFakeTelegram(),
+		storage:  &memoryStorage{},
+	}
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("token", env.telegram.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.handler = NewMessageHandler(testUser, env.skyeng.Client(), state.NewState(1), env.storage)
+	// Sender is not running, commands are never applied.
+	env.handler.ackTimeout = time.Millisecond
+	err = env.handler.init(context.Background(), api)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func (env *testEnv) close() {
+	env.skyeng.Close()
+	env.telegram.Close()
+}
+
+func message(text string) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			Text: text,
+			From: &tgbotapi.User{UserName: testUser},
+			Chat: &tgbotapi.Chat{ID: 1},
+		},
+	}
+}
+
+func callback(data string) tgbotapi.Update {
+	return tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   "1",
+			Data: data,
+			From: &tgbotapi.User{UserName: testUser},
+			Message: &tgbotapi.Message{
+				MessageID: 1,
+				Chat:      &tgbotapi.Chat{ID: 1},
+			},
+		},
+	}
+}
+
+func TestHandleShowWordsetsNavigation(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	for i := 0; i < 12; i++ {
+		env.skyeng.AddWordset(fmt.Sprintf("wordset %d", i))
+	}
+
+	err := env.handler.handle(context.Background(), message(actionGetWordsets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ := env.telegram.last("sendMessage")
+	markup := sent.Get("reply_markup")
+	if !strings.Contains(markup, "wordset 9") || strings.Contains(markup, "wordset 10") {
+		t.Errorf("expected first page of wordsets, got %s", markup)
+	}
+	if !strings.Contains(markup, "next 2") || strings.Contains(markup, "prev") {
+		t.Errorf("expected only next button, got %s", markup)
+	}
+
+	err = env.handler.handle(context.Background(), callback("next 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ = env.telegram.last("sendMessage")
+	markup = sent.Get("reply_markup")
+	if !strings.Contains(markup, "wordset 11") || strings.Contains(markup, "next") || !strings.Contains(markup, "prev 1") {
+		t.Errorf("expected last page with prev button only, got %s", markup)
+	}
+	if _, ok := env.telegram.last("answerCallbackQuery"); !ok {
+		t.Error("callback query is not answered")
+	}
+}
+
+func TestHandleUnknownUser(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	upd := message(actionGetWordsets)
+	upd.Message.From.UserName = "stranger"
+
+	err := env.handler.handle(context.Background(), upd)
+	if err == nil {
+		t.Fatal("expected authorization error")
+	}
+	if _, ok := env.telegram.last("sendMessage"); ok {
+		t.Error("unexpected message to unknown user")
+	}
+}
+
+func TestHandleFreeTextSearch(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	env.skyeng.AddMeaning(skyeng.Meaning{Text: "decision", Translation: skyeng.Translation{Text: "решение"}})
+
+	err := env.handler.handle(context.Background(), message("decision"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ := env.telegram.last("sendMessage")
+	if !strings.Contains(sent.Get("text"), "decision") || !strings.Contains(sent.Get("text"), "решение") {
+		t.Errorf("unexpected search reply %q", sent.Get("text"))
+	}
+}
+
+func TestHandleSaveWord(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	ws := env.skyeng.AddWordset("saved")
+	m := env.skyeng.AddMeaning(skyeng.Meaning{Text: "decision"})
+
+	err := env.handler.handle(context.Background(), callback(fmt.Sprintf("%s %d", callbackSaveWord, m.ID)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ := env.telegram.last("sendMessage")
+	addData := fmt.Sprintf("%s %d %d", callbackAddWord, ws.ID, m.ID)
+	if !strings.Contains(sent.Get("reply_markup"), addData) {
+		t.Fatalf("expected wordset choice, got %s", sent.Get("reply_markup"))
+	}
+
+	err = env.handler.handle(context.Background(), callback(addData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := env.skyeng.Words(ws.ID); len(words) != 1 || words[0].MeaningID != m.ID {
+		t.Fatalf("word is not saved, words %+v", words)
+	}
+}
+
+func TestSetWordsetSending(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	ws := env.skyeng.AddWordset("daily", skyeng.Meaning{Text: "decision"})
+
+	err := env.handler.handle(context.Background(), callback(fmt.Sprintf("%s %d %s", callbackSetWordset, ws.ID, ws.Title)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.storage.data.WordsetID != ws.ID || env.storage.data.ChatID != 1 {
+		t.Fatalf("wordset is not stored, data %+v", env.storage.data)
+	}
+
+	card, err := env.handler.state.WordsetCallback(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.handler.Notifier().Notify(context.Background(), card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ := env.telegram.last("sendMessage")
+	if !strings.Contains(sent.Get("reply_markup"), "decision") {
+		t.Errorf("expected wordset words keyboard, got %s", sent.Get("reply_markup"))
+	}
+}
+
+func TestMarkKnown(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	ws := env.skyeng.AddWordset("daily", skyeng.Meaning{Text: "decision"}, skyeng.Meaning{Text: "postpone"})
+	words := env.skyeng.Words(ws.ID)
+
+	err := env.handler.MarkKnown(context.Background(), words[0].MeaningID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if known := env.storage.data.Known; len(known) != 1 || known[0] != words[0].MeaningID {
+		t.Fatalf("known meaning is not stored, data %+v", env.storage.data)
+	}
+	card, err := env.handler.wordsetCard(context.Background(), ws.ID, ws.Title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(card.Meanings) != 1 || card.Meanings[0].Text != "postpone" {
+		t.Errorf("expected known word to be skipped, got %+v", card.Meanings)
+	}
+}
+
+func TestHandleSchedule(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	err := env.handler.handle(context.Background(), message(actionSchedule+" TZ=Europe/Moscow daily 08:00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.storage.data.Schedule != "daily 08:00" || env.storage.data.Timezone != "Europe/Moscow" {
+		t.Fatalf("schedule is not stored, data %+v", env.storage.data)
+	}
+	loc, _ := time.LoadLocation("Europe/Moscow")
+	next := env.handler.state.GetSchedule().Next(time.Date(2020, 5, 8, 9, 0, 0, 0, loc))
+	if !next.Equal(time.Date(2020, 5, 9, 8, 0, 0, 0, loc)) {
+		t.Errorf("schedule is not applied, next send at %s", next)
+	}
+
+	err = env.handler.handle(context.Background(), message(actionSchedule+" daily 25:00"))
+	if err == nil {
+		t.Error("expected invalid schedule error")
+	}
+	if env.storage.data.Schedule != "daily 08:00" {
+		t.Errorf("invalid schedule is stored, data %+v", env.storage.data)
+	}
+}
+
+func TestHandleQuietAndDND(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	err := env.handler.handle(context.Background(), message(actionQuiet+" TZ=UTC 22:00-08:00 23:00-10:00 defer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := storage.Quiet{Weekdays: "22:00-08:00", Weekends: "23:00-10:00", Defer: true}
+	if env.storage.data.Quiet != expected || env.storage.data.Timezone != "UTC" {
+		t.Fatalf("quiet hours are not stored, data %+v", env.storage.data)
+	}
+	quiet, deferred := env.handler.state.GetQuietHours()
+	if quiet == nil || !deferred {
+		t.Fatal("quiet hours are not applied")
+	}
+
+	err = env.handler.handle(context.Background(), message(actionDND+" 2h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dnd := env.handler.state.GetDND(); time.Until(dnd) < time.Hour || !dnd.Equal(env.storage.data.DNDUntil) {
+		t.Fatalf("do not disturb is not applied, until %s", dnd)
+	}
+
+	err = env.handler.handle(context.Background(), message(actionQuiet+" off"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quiet, _ := env.handler.state.GetQuietHours(); quiet != nil || env.storage.data.Quiet.Weekdays != "" {
+		t.Error("quiet hours are not disabled")
+	}
+}
+
+func TestSpacedRepetition(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	ws := env.skyeng.AddWordset("daily", skyeng.Meaning{Text: "decision"}, skyeng.Meaning{Text: "postpone"})
+	words := env.skyeng.Words(ws.ID)
+
+	err := env.handler.handle(context.Background(), message(actionSRS+" on"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.storage.data.SRS {
+		t.Fatalf("spaced repetition is not stored, data %+v", env.storage.data)
+	}
+	err = env.handler.handle(context.Background(), callback(fmt.Sprintf("%s %d %s", callbackSetWordset, ws.ID, ws.Title)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := env.handler.state.WordsetCallback(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !card.Review || len(card.Meanings) != 1 || card.Meanings[0].ID != words[0].MeaningID {
+		t.Fatalf("expected review card of the first word, got %+v", card)
+	}
+	err = env.handler.Notifier().Notify(context.Background(), card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ := env.telegram.last("sendMessage")
+	gradeData := fmt.Sprintf("%s %d %s", callbackGrade, words[0].MeaningID, srs.Good)
+	if !strings.Contains(sent.Get("reply_markup"), gradeData) {
+		t.Fatalf("expected grade buttons, got %s", sent.Get("reply_markup"))
+	}
+
+	err = env.handler.handle(context.Background(), callback(gradeData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, ok := env.storage.data.Reviews[words[0].MeaningID]
+	if !ok || time.Until(item.Due) < 23*time.Hour {
+		t.Fatalf("grade is not stored, reviews %+v", env.storage.data.Reviews)
+	}
+	card, err = env.handler.state.WordsetCallback(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(card.Meanings) != 1 || card.Meanings[0].ID != words[1].MeaningID {
+		t.Errorf("expected the next due word, got %+v", card.Meanings)
+	}
+}
+
+func TestGradeDuringSend(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	ws := env.skyeng.AddWordset("daily", skyeng.Meaning{Text: "decision"}, skyeng.Meaning{Text: "postpone"})
+	words := env.skyeng.Words(ws.ID)
+	err := env.handler.handle(context.Background(), message(actionSRS+" on"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.handler.handle(context.Background(), callback(fmt.Sprintf("%s %d %s", callbackSetWordset, ws.ID, ws.Title)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			card, err := env.handler.state.WordsetCallback(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			err = env.handler.Notifier().Notify(context.Background(), card)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		w := words[i%len(words)]
+		err = env.handler.handle(context.Background(), callback(fmt.Sprintf("%s %d %s", callbackGrade, w.MeaningID, srs.Good)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	if len(env.storage.data.Reviews) != len(words) {
+		t.Errorf("expected grades of all words, reviews %+v", env.storage.data.Reviews)
+	}
+}
+
+func TestSuspendResume(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+
+	err := env.handler.handle(context.Background(), message(actionSuspend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.storage.data.Suspended || !env.handler.state.GetStatus().Suspended {
+		t.Fatalf("sending is not suspended, data %+v", env.storage.data)
+	}
+
+	// Restart keeps sending suspended.
+	restarted := NewMessageHandler(testUser, env.skyeng.Client(), state.NewState(1), env.storage)
+	err = restarted.setupState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.state.GetStatus().Suspended {
+		t.Fatal("sending is resumed after restart")
+	}
+
+	err = env.handler.handle(context.Background(), message(actionResume))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.storage.data.Suspended || env.handler.state.GetStatus().Suspended {
+		t.Fatalf("sending is not resumed, data %+v", env.storage.data)
+	}
+
+	err = env.handler.handle(context.Background(), message(actionSuspend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.handler.handle(context.Background(), message(actionChangeInterval+" 30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.storage.data.Suspended {
+		t.Error("interval change does not resume sending")
+	}
+}
+
+func TestWordsetWordsKeepCache(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	ws := env.skyeng.AddWordset("daily", skyeng.Meaning{Text: "decision"}, skyeng.Meaning{Text: "postpone"})
+	words := env.skyeng.Words(ws.ID)
+	env.handler.skyengClient = skyeng.NewCachingClient(env.skyeng.Client(), skyeng.DefaultCacheOptions())
+
+	err := env.handler.MarkKnown(context.Background(), words[0].MeaningID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := env.handler.wordsetWords(context.Background(), ws.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 {
+		t.Fatalf("expected known word to be skipped, got %+v", filtered)
+	}
+
+	err = env.handler.updateData(func(data *storage.Data) {
+		data.Known = nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err = env.handler.wordsetWords(context.Background(), ws.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 2 {
+		t.Errorf("expected cached words to be kept, got %+v", filtered)
+	}
+}
+
+func TestReviewStatsUseUserTimezone(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	// Dates of these zones always differ, so one of them differs from the server date.
+	timezone := "Pacific/Kiritimati"
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Now().In(loc).Format("2006-01-02") == time.Now().Format("2006-01-02") {
+		timezone = "Etc/GMT+12"
+		loc, err = time.LoadLocation(timezone)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = env.handler.updateData(func(data *storage.Data) {
+		data.Timezone = timezone
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hookNow time.Time
+	env.handler.OnStats(func(ctx context.Context, stats storage.Stats, now time.Time) error {
+		hookNow = now
+		return nil
+	})
+
+	err = env.handler.MarkKnown(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().In(loc).Format("2006-01-02")
+	if env.storage.data.Stats.Day != today {
+		t.Errorf("expected review day %s of user timezone, got %s", today, env.storage.data.Stats.Day)
+	}
+	if hookNow.Location().String() != timezone {
+		t.Errorf("expected stats hook time in %s, got %s", timezone, hookNow.Location())
+	}
+}
+
+func TestReviewCountsMeaningOncePerDay(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.close()
+	var published int
+	env.handler.OnStats(func(ctx context.Context, stats storage.Stats, now time.Time) error {
+		published++
+		return nil
+	})
+
+	for _, meaningID := range []int{1, 1, 2, 1} {
+		err := env.handler.recordReview(context.Background(), meaningID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := env.handler.MarkKnown(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.storage.data.Stats.Reviewed != 2 || published != 2 {
+		t.Errorf("expected each meaning to be counted once, got %d reviews and %d published stats", env.storage.data.Stats.Reviewed, published)
+	}
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
+	"github.com/pkg/errors"
+)
+
+// Notifier returns Notifier sending cards to the chat the bot is started in.
+func (h *MessageHandler) Notifier() notifier.Notifier {
+	return &telegramNotifier{
+		handler: h,
+	}
+}
+
+type telegramNotifier struct {
+	handler *MessageHandler
+}
+
+func (n *telegramNotifier) Notify(ctx context.Context, card *notifier.Card) error {
+	h := n.handler
+	data := h.getData()
+	if h.api == nil || data == nil || data.ChatID == 0 {
+		return errors.New("telegram chat is unknown, start the bot first")
+	}
+	resp := tgbotapi.NewMessage(data.ChatID, "Choose word to show translation and examples.")
+	resp.ParseMode = tgbotapi.ModeHTML
+	resp.DisableNotification = card.Silent
+	resp.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(wordsButtons(card, 0, nil)...)
+	_, err := h.api.Send(resp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
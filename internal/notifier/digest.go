@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxDigestCards limits collected cards while delivery fails, the oldest cards are dropped.
+	maxDigestCards = 1000
+	// digestFlushTimeout limits delivery of collected cards on shutdown.
+	digestFlushTimeout = 30 * time.Second
+)
+
+// DigestSender delivers several cards at once.
+type DigestSender interface {
+	SendDigest(ctx context.Context, cards []*Card) error
+}
+
+// NewDigest returns Digest delivering collected cards once per period. Periods are counted from
+// the zero time in UTC, so a daily digest is delivered at UTC midnight and a weekly one on Monday.
+func NewDigest(sender DigestSender, period time.Duration) *Digest {
+	return &Digest{
+		sender: sender,
+		period: period,
+	}
+}
+
+// Digest is Notifier collecting cards produced by the sender and delivering them together.
+type Digest struct {
+	sender DigestSender
+	period time.Duration
+
+	mx    sync.Mutex
+	cards []*Card
+}
+
+// Notify collects card until the next digest.
+func (d *Digest) Notify(ctx context.Context, card *Card) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.cards = append(d.cards, card)
+	d.limit()
+
+	return nil
+}
+
+// limit drops the oldest cards exceeding maxDigestCards.
+func (d *Digest) limit() {
+	if dropped := len(d.cards) - maxDigestCards; dropped > 0 {
+		logrus.Warnf("Digest is too big, %d oldest cards are dropped", dropped)
+		d.cards = append([]*Card(nil), d.cards[dropped:]...)
+	}
+}
+
+// Flush delivers collected cards, cards are kept for the next digest if delivery failed.
+func (d *Digest) Flush(ctx context.Context) error {
+	d.mx.Lock()
+	cards := d.cards
+	d.cards = nil
+	d.mx.Unlock()
+	if len(cards) == 0 {
+		return nil
+	}
+	err := d.sender.SendDigest(ctx, cards)
+	if err != nil {
+		d.mx.Lock()
+		d.cards = append(cards, d.cards...)
+		d.limit()
+		d.mx.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Run delivers digest every period until context is done, collected cards are delivered on shutdown.
+func (d *Digest) Run(ctx context.Context) error {
+	timer := time.NewTimer(d.untilNext(time.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			err := d.Flush(ctx)
+			if err != nil {
+				logrus.Error("failed to deliver digest, got ", err)
+			}
+			timer.Reset(d.untilNext(time.Now()))
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), digestFlushTimeout)
+			defer cancel()
+			err := d.Flush(flushCtx)
+			if err != nil {
+				logrus.Error("failed to deliver digest on shutdown, got ", err)
+			}
+			return nil
+		}
+	}
+}
+
+// untilNext returns duration until the next period boundary.
+func (d *Digest) untilNext(now time.Time) time.Duration {
+	return now.Truncate(d.period).Add(d.period).Sub(now)
+}
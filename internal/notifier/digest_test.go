@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDigestSender struct {
+	mx   sync.Mutex
+	err  error
+	sent [][]*Card
+}
+
+func (s *fakeDigestSender) SendDigest(ctx context.Context, cards []*Card) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	s.sent = append(s.sent, cards)
+	return nil
+}
+
+func TestDigestFlushOnShutdown(t *testing.T) {
+	sender := &fakeDigestSender{}
+	digest := NewDigest(sender, 24*time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- digest.Run(ctx)
+	}()
+	err := digest.Notify(ctx, card(1, "decision"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest is not stopped")
+	}
+	if len(sender.sent) != 1 || len(sender.sent[0]) != 1 {
+		t.Errorf("expected collected card to be delivered on shutdown, got %v", sender.sent)
+	}
+}
+
+func TestDigestLimit(t *testing.T) {
+	sender := &fakeDigestSender{err: errors.New("smtp is down")}
+	digest := NewDigest(sender, time.Hour)
+	for i := 0; i < maxDigestCards+10; i++ {
+		err := digest.Notify(context.Background(), card(i, "decision"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := digest.Flush(context.Background()); err == nil {
+		t.Fatal("expected delivery error")
+	}
+
+	sender.err = nil
+	err := digest.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards := sender.sent[0]
+	if len(cards) != maxDigestCards || cards[0].Meanings[0].ID != 10 {
+		t.Errorf("expected the newest %d cards, got %d starting with %d", maxDigestCards, len(cards), cards[0].Meanings[0].ID)
+	}
+}
+
+func TestDigestPeriodBoundary(t *testing.T) {
+	digest := NewDigest(&fakeDigestSender{}, 24*time.Hour)
+	now := time.Date(2026, 5, 11, 15, 30, 0, 0, time.UTC)
+	if next := now.Add(digest.untilNext(now)); !next.Equal(time.Date(2026, 5, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected daily digest at UTC midnight, got %s", next)
+	}
+}
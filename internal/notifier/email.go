@@ -0,0 +1,220 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pkg/errors"
+)
+
+const defaultEmailSubject = "Skyeng words"
+
+var emailTextTemplate = template.Must(template.New("text").Parse(`{{range .Meanings -}}
+{{.Text}}{{if .Transcription}} [{{.Transcription}}]{{end}} — {{.Translation.Text}}
+{{range .Examples}}  * {{.Text}}
+{{end}}
+{{end}}`))
+
+var emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<html>
+<body>
+<h2>{{.Subject}}</h2>
+{{range .Meanings}}
+<p>
+<b>{{.Text}}</b>{{if .Transcription}} [{{.Transcription}}]{{end}} — {{.Translation.Text}}
+{{if .Examples}}<ul>{{range .Examples}}<li>{{.Text}}</li>{{end}}</ul>{{end}}
+</p>
+{{end}}
+</body>
+</html>
+`))
+
+// EmailOptions represents SMTP server and message parameters.
+type EmailOptions struct {
+	// Addr is SMTP server address as host:port.
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  string
+	Timeout  time.Duration
+}
+
+// NewEmail returns Email sending cards over SMTP.
+func NewEmail(opts EmailOptions) *Email {
+	if opts.Subject == "" {
+		opts.Subject = defaultEmailSubject
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return &Email{
+		opts: opts,
+	}
+}
+
+// Email is Notifier sending cards over SMTP, use it with Digest to send periodic digests.
+type Email struct {
+	opts EmailOptions
+}
+
+type emailData struct {
+	Subject  string
+	Meanings []skyeng.Meaning
+}
+
+// Notify sends email with the single card.
+func (e *Email) Notify(ctx context.Context, card *Card) error {
+	return e.SendDigest(ctx, []*Card{card})
+}
+
+// SendDigest sends single email with words of all cards, repeated words are sent once.
+func (e *Email) SendDigest(ctx context.Context, cards []*Card) error {
+	data := emailData{Subject: e.opts.Subject}
+	seen := make(map[int]bool)
+	for _, card := range cards {
+		for _, m := range card.Meanings {
+			if seen[m.ID] {
+				continue
+			}
+			seen[m.ID] = true
+			data.Meanings = append(data.Meanings, m)
+		}
+	}
+	if len(data.Meanings) == 0 {
+		return nil
+	}
+	msg, err := e.message(data)
+	if err != nil {
+		return err
+	}
+
+	return e.send(ctx, msg)
+}
+
+// message returns multipart/alternative email with plain text and HTML parts.
+func (e *Email) message(data emailData) ([]byte, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	parts := []struct {
+		contentType string
+		execute     func(buf *bytes.Buffer) error
+	}{
+		{"text/plain; charset=utf-8", func(buf *bytes.Buffer) error { return emailTextTemplate.Execute(buf, data) }},
+		{"text/html; charset=utf-8", func(buf *bytes.Buffer) error { return emailHTMLTemplate.Execute(buf, data) }},
+	}
+	for _, p := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", p.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		buf := &bytes.Buffer{}
+		err = p.execute(buf)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		qp := quotedprintable.NewWriter(part)
+		_, err = qp.Write(buf.Bytes())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", e.opts.From)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(e.opts.To, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", data.Subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// send delivers message using STARTTLS if server supports it.
+func (e *Email) send(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+	host, _, err := net.SplitHostPort(e.opts.Addr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", e.opts.Addr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close()
+		return errors.WithStack(err)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errors.WithStack(err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if e.opts.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", e.opts.Username, e.opts.Password, host))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	err = c.Mail(e.opts.From)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, to := range e.opts.To {
+		err = c.Rcpt(to)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = w.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(c.Quit())
+}
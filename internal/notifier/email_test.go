@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+)
+
+// smtpServer is local SMTP stand-in accepting messages without authentication.
+type smtpServer struct {
+	ln       net.Listener
+	messages chan string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.messages <- data.String()
+			reply("250 accepted")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	srv := newSMTPServer(t)
+	defer srv.ln.Close()
+	email := NewEmail(EmailOptions{
+		Addr:    srv.ln.Addr().String(),
+		From:    "bot@example.com",
+		To:      []string{"user@example.com"},
+		Subject: "Words of the week",
+	})
+	digest := NewDigest(email, time.Hour)
+	decision := skyeng.Meaning{
+		ID:            1,
+		Text:          "decision",
+		Transcription: "dɪˈsɪʒn",
+		Translation:   skyeng.Translation{Text: "решение"},
+		Examples:      []skyeng.Example{{Text: "It was a <hard> decision."}},
+	}
+	postpone := skyeng.Meaning{ID: 2, Text: "postpone", Translation: skyeng.Translation{Text: "отложить"}}
+	for _, card := range []*Card{
+		{WordsetName: "daily", Meanings: []skyeng.Meaning{decision}},
+		{WordsetName: "daily", Meanings: []skyeng.Meaning{decision, postpone}},
+	} {
+		err := digest.Notify(context.Background(), card)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-srv.messages:
+		t.Fatal("digest is sent before flush")
+	default:
+	}
+
+	err := digest.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw string
+	select {
+	case raw = <-srv.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest is not sent")
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Words of the week" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := ioutil.ReadAll(p)
+		parts[strings.Split(p.Header.Get("Content-Type"), ";")[0]] = string(body)
+	}
+	text := parts["text/plain"]
+	if strings.Count(text, "decision [dɪˈsɪʒn] — решение") != 1 || !strings.Contains(text, "postpone — отложить") {
+		t.Errorf("unexpected text part %q", text)
+	}
+	if !strings.Contains(parts["text/html"], "<b>decision</b>") || !strings.Contains(parts["text/html"], "&lt;hard&gt;") {
+		t.Errorf("unexpected html part %q", parts["text/html"])
+	}
+
+	err = digest.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-srv.messages:
+		t.Fatal("empty digest is sent")
+	default:
+	}
+}
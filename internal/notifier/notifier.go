@@ -0,0 +1,94 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Card represents word card pushed to user.
+type Card struct {
+	WordsetID   int
+	WordsetName string
+	Meanings    []skyeng.Meaning
+	// Silent asks to deliver card without sound, used near quiet hours.
+	Silent bool
+	// Review asks user to grade recall of the card word.
+	Review bool
+}
+
+// Title returns card title.
+func (c *Card) Title() string {
+	return c.WordsetName
+}
+
+// Text returns plain text representation of the card, one word per line.
+func (c *Card) Text() string {
+	builder := strings.Builder{}
+	for _, m := range c.Meanings {
+		builder.WriteString(MeaningLine(m))
+		builder.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// HTML returns card representation with simple HTML formatting, words are bold.
+func (c *Card) HTML() string {
+	builder := strings.Builder{}
+	for _, m := range c.Meanings {
+		builder.WriteString("<b>" + html.EscapeString(m.Text) + "</b>")
+		if m.Transcription != "" {
+			builder.WriteString(" [" + html.EscapeString(m.Transcription) + "]")
+		}
+		builder.WriteString(" — " + html.EscapeString(m.Translation.Text))
+		builder.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// MeaningLine returns short one line representation of the meaning.
+func MeaningLine(m skyeng.Meaning) string {
+	if m.Transcription == "" {
+		return fmt.Sprintf("%s — %s", m.Text, m.Translation.Text)
+	}
+
+	return fmt.Sprintf("%s [%s] — %s", m.Text, m.Transcription, m.Translation.Text)
+}
+
+// Notifier delivers word cards to user.
+type Notifier interface {
+	Notify(ctx context.Context, card *Card) error
+}
+
+// NewMulti returns Notifier delivering cards to every notifier.
+func NewMulti(notifiers ...Notifier) Notifier {
+	return multi(notifiers)
+}
+
+type multi []Notifier
+
+// Notify delivers card to all notifiers, failure of one notifier does not stop others.
+func (m multi) Notify(ctx context.Context, card *Card) error {
+	var failed int
+	var lastErr error
+	for _, n := range m {
+		err := n.Notify(ctx, card)
+		if err != nil {
+			logrus.Error("failed to deliver card, got ", err)
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return errors.Wrapf(lastErr, "%d of %d notifiers failed", failed, len(m))
+	}
+
+	return nil
+}
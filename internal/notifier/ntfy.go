@@ -0,0 +1,204 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Paths of the HTTP server handling ntfy actions, request body is NtfyAnswer.
+const (
+	NtfyKnownPath    = "/ntfy/known"
+	NtfyExamplesPath = "/ntfy/examples"
+)
+
+// ntfyLowPriority is used for silent cards, it disables sound and vibration.
+const ntfyLowPriority = 2
+
+// NtfyAnswer represents body of ntfy action request. Actions are visible to every topic subscriber,
+// so the signature authorizes actions on the meaning only.
+type NtfyAnswer struct {
+	MeaningID int    `json:"meaning_id"`
+	Signature string `json:"signature"`
+}
+
+// SignNtfyAnswer returns signature of the action on the meaning, HMAC-SHA256 of the meaning ID.
+func SignNtfyAnswer(secret string, meaningID int) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.Itoa(meaningID)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyNtfyAnswer reports whether the answer is signed with the secret.
+func VerifyNtfyAnswer(secret string, answer *NtfyAnswer) bool {
+	if secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(SignNtfyAnswer(secret, answer.MeaningID)), []byte(answer.Signature))
+}
+
+// NtfyOptions represents ntfy server and message parameters.
+type NtfyOptions struct {
+	// Server is ntfy server URL, https://ntfy.sh by default.
+	Server string
+	Topic  string
+	// Token is ntfy access token, anonymous access is used if empty.
+	Token string
+	// Priority from 1 (min) to 5 (max), server default is used if zero.
+	Priority int
+	Tags     []string
+	// Click is URL opened on notification tap.
+	Click string
+	// CallbackURL is public URL of the notificator HTTP server, action buttons are not added if empty.
+	CallbackURL string
+	// CallbackSecret signs action requests, action buttons are not added if empty.
+	CallbackSecret string
+}
+
+// NewNtfy returns Ntfy publishing cards to ntfy topic.
+func NewNtfy(opts NtfyOptions) *Ntfy {
+	if opts.Server == "" {
+		opts.Server = "https://ntfy.sh"
+	}
+	if opts.CallbackURL != "" && opts.CallbackSecret == "" {
+		logrus.Warn("Ntfy callback secret is not set, action buttons are disabled")
+	}
+	return &Ntfy{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		next:   make(map[int]int),
+	}
+}
+
+// Ntfy is Notifier publishing single word of the card per push, words of the card are taken in turn.
+type Ntfy struct {
+	opts   NtfyOptions
+	client *http.Client
+
+	mx sync.Mutex
+	// next keeps index of the next word to push per wordset.
+	next map[int]int
+}
+
+type ntfyMessage struct {
+	Topic    string       `json:"topic"`
+	Title    string       `json:"title,omitempty"`
+	Message  string       `json:"message"`
+	Priority int          `json:"priority,omitempty"`
+	Tags     []string     `json:"tags,omitempty"`
+	Click    string       `json:"click,omitempty"`
+	Actions  []ntfyAction `json:"actions,omitempty"`
+}
+
+type ntfyAction struct {
+	Action  string            `json:"action"`
+	Label   string            `json:"label"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Clear   bool              `json:"clear,omitempty"`
+}
+
+// Notify publishes the next word of the card with "I know it" and "Show examples" actions.
+func (n *Ntfy) Notify(ctx context.Context, card *Card) error {
+	if len(card.Meanings) == 0 {
+		return nil
+	}
+	n.mx.Lock()
+	i := n.next[card.WordsetID] % len(card.Meanings)
+	n.next[card.WordsetID] = i + 1
+	n.mx.Unlock()
+	m := card.Meanings[i]
+
+	msg := n.message(card.Title(), MeaningLine(m))
+	if card.Silent {
+		msg.Priority = ntfyLowPriority
+	}
+	if n.opts.CallbackURL != "" && n.opts.CallbackSecret != "" {
+		msg.Actions = []ntfyAction{
+			n.callbackAction("I know it", NtfyKnownPath, m.ID, true),
+			n.callbackAction("Show examples", NtfyExamplesPath, m.ID, false),
+		}
+	}
+
+	return n.publish(ctx, msg)
+}
+
+// SendExamples publishes usage examples of the meaning.
+func (n *Ntfy) SendExamples(ctx context.Context, m skyeng.Meaning) error {
+	var examples []string
+	for _, e := range m.Examples {
+		examples = append(examples, "• "+e.Text)
+	}
+	if len(examples) == 0 {
+		examples = append(examples, "No examples found.")
+	}
+
+	return n.publish(ctx, n.message(MeaningLine(m), strings.Join(examples, "\n")))
+}
+
+func (n *Ntfy) message(title string, text string) *ntfyMessage {
+	return &ntfyMessage{
+		Topic:    n.opts.Topic,
+		Title:    title,
+		Message:  text,
+		Priority: n.opts.Priority,
+		Tags:     n.opts.Tags,
+		Click:    n.opts.Click,
+	}
+}
+
+func (n *Ntfy) callbackAction(label string, path string, meaningID int, clear bool) ntfyAction {
+	body, _ := json.Marshal(NtfyAnswer{MeaningID: meaningID, Signature: SignNtfyAnswer(n.opts.CallbackSecret, meaningID)})
+
+	return ntfyAction{
+		Action: "http",
+		Label:  label,
+		URL:    strings.TrimSuffix(n.opts.CallbackURL, "/") + path,
+		Method: "POST",
+		Body:   string(body),
+		Clear:  clear,
+	}
+}
+
+func (n *Ntfy) publish(ctx context.Context, msg *ntfyMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", n.opts.Server, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.opts.Token)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return errors.WithStack(fmt.Errorf("failed to publish to ntfy, got status %d, message %s", resp.StatusCode, respBody))
+	}
+
+	return nil
+}
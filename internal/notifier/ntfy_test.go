@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+)
+
+func TestNtfyNotify(t *testing.T) {
+	published := make(chan ntfyMessage, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer tk_test" {
+			t.Errorf("unexpected authorization %q", req.Header.Get("Authorization"))
+		}
+		var msg ntfyMessage
+		err := json.NewDecoder(req.Body).Decode(&msg)
+		if err != nil {
+			t.Error(err)
+		}
+		published <- msg
+	}))
+	defer srv.Close()
+	n := NewNtfy(NtfyOptions{
+		Server:         srv.URL,
+		Topic:          "words",
+		Token:          "tk_test",
+		Priority:       4,
+		Tags:           []string{"books"},
+		CallbackURL:    "https://notificator.example.com/",
+		CallbackSecret: "secret",
+	})
+	card := &Card{WordsetID: 1, WordsetName: "daily", Meanings: []skyeng.Meaning{
+		{ID: 1, Text: "decision", Translation: skyeng.Translation{Text: "решение"}},
+		{ID: 2, Text: "postpone", Translation: skyeng.Translation{Text: "отложить"}},
+	}}
+
+	for _, expected := range []string{"decision — решение", "postpone — отложить", "decision — решение"} {
+		err := n.Notify(context.Background(), card)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := <-published
+		if msg.Message != expected || msg.Title != "daily" || msg.Topic != "words" || msg.Priority != 4 {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+
+	err := n.Notify(context.Background(), &Card{WordsetID: 2, Meanings: card.Meanings[1:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := <-published
+	if len(msg.Actions) != 2 {
+		t.Fatalf("expected 2 actions, got %+v", msg.Actions)
+	}
+	known := msg.Actions[0]
+	var answer NtfyAnswer
+	err = json.Unmarshal([]byte(known.Body), &answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if known.URL != "https://notificator.example.com"+NtfyKnownPath || answer.MeaningID != 2 ||
+		len(known.Headers) != 0 || !known.Clear {
+		t.Errorf("unexpected known action %+v", known)
+	}
+	if !VerifyNtfyAnswer("secret", &answer) {
+		t.Errorf("action is not signed, answer %+v", answer)
+	}
+	answer.MeaningID = 1
+	if VerifyNtfyAnswer("secret", &answer) {
+		t.Error("signature of meaning 2 is accepted for meaning 1")
+	}
+	if msg.Actions[1].URL != "https://notificator.example.com"+NtfyExamplesPath {
+		t.Errorf("unexpected examples action %+v", msg.Actions[1])
+	}
+}
@@ -0,0 +1,318 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/pushover"
+	"github.com/pachmu/skyeng-push-notificator/internal/storage"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxImageSize limits downloaded card image, bigger images are not attached.
+	maxImageSize = 2621440
+	// maxDigestMeanings limits digest size to fit Pushover message length.
+	maxDigestMeanings     = 15
+	defaultDigestInterval = 24 * time.Hour
+	// maxPushMeanings limits words per push, words of the card are pushed in turn.
+	maxPushMeanings = 5
+	// maxPushoverTitle is a Pushover limit of the title length.
+	maxPushoverTitle = 250
+	// receiptWatchMargin extends receipt watching after expiration to get the expired status.
+	receiptWatchMargin = time.Minute
+)
+
+// PushoverOptions represents parameters of pushes sent to Pushover.
+type PushoverOptions struct {
+	Priority int
+	Sound    string
+	HTML     bool
+	URL      string
+	URLTitle string
+	TTL      time.Duration
+	// Retry and Expire are used with emergency priority.
+	Retry  time.Duration
+	Expire time.Duration
+	// AttachImage attaches image of the first card word having one.
+	AttachImage bool
+	// LowQuota is a count of remaining monthly pushes below which cards are merged
+	// into digest sent once per DigestInterval, a day by default.
+	LowQuota       int
+	DigestInterval time.Duration
+	// OnReceipt receives status of emergency push once it is acknowledged or expired,
+	// err is set if the status is unknown. Status is only logged if it is nil.
+	OnReceipt func(receipt string, r *pushover.Receipt, err error)
+}
+
+// NewPushover returns Notifier sending cards to Pushover, receipts of emergency pushes
+// are watched until the application context is done.
+func NewPushover(ctx context.Context, client pushover.Client, opts PushoverOptions) Notifier {
+	return &pushoverNotifier{
+		lifetime: ctx,
+		client:   client,
+		opts:     opts,
+		http:     &http.Client{Timeout: 10 * time.Second},
+		next:     make(map[int]int),
+	}
+}
+
+type pushoverNotifier struct {
+	// lifetime bounds receipt watching, it is not canceled with the send.
+	lifetime context.Context
+	client   pushover.Client
+	opts     PushoverOptions
+	http     *http.Client
+
+	mx         sync.Mutex
+	digest     *Card
+	lastDigest time.Time
+	// next keeps index of the next word to push per wordset.
+	next map[int]int
+}
+
+// Notify sends card to Pushover, emergency pushes are watched until acknowledged in background
+// and the status is passed to OnReceipt.
+// Cards are merged into digest while application quota is low.
+func (n *pushoverNotifier) Notify(ctx context.Context, card *Card) error {
+	card = n.throttle(n.rotate(card), time.Now())
+	if card == nil {
+		return nil
+	}
+	text, isHTML := n.text(card)
+	msg := &pushover.Message{
+		Title:    truncate(card.Title(), maxPushoverTitle),
+		Message:  text,
+		HTML:     isHTML,
+		Priority: n.opts.Priority,
+		Sound:    n.opts.Sound,
+		URL:      n.opts.URL,
+		URLTitle: n.opts.URLTitle,
+		TTL:      n.opts.TTL,
+		Retry:    n.opts.Retry,
+		Expire:   n.opts.Expire,
+	}
+	if card.Silent && msg.Priority == pushover.PriorityNormal {
+		msg.Priority = pushover.PriorityLow
+	}
+	if n.opts.AttachImage {
+		attachment, err := n.image(ctx, card)
+		if err != nil {
+			logrus.Error("failed to attach card image, got ", err)
+		}
+		msg.Attachment = attachment
+	}
+	resp, err := n.client.Send(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if resp.Receipt != "" {
+		go n.watchReceipt(resp.Receipt)
+	}
+
+	return nil
+}
+
+// rotate returns card with words of the next push, at most maxPushMeanings words fitting
+// Pushover message are pushed and the next push continues with the following words.
+func (n *pushoverNotifier) rotate(card *Card) *Card {
+	if len(card.Meanings) == 0 {
+		return card
+	}
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	start := n.next[card.WordsetID] % len(card.Meanings)
+	push := *card
+	push.Meanings = nil
+	for i := 0; i < len(card.Meanings) && i < maxPushMeanings; i++ {
+		push.Meanings = append(push.Meanings, card.Meanings[(start+i)%len(card.Meanings)])
+		if len(push.Meanings) > 1 && !n.fits(&push) {
+			push.Meanings = push.Meanings[:len(push.Meanings)-1]
+			break
+		}
+	}
+	n.next[card.WordsetID] = start + len(push.Meanings)
+
+	return &push
+}
+
+// text returns message of the card and whether it is HTML. Words not fitting Pushover message
+// are dropped, the single word not fitting is truncated as plain text.
+func (n *pushoverNotifier) text(card *Card) (string, bool) {
+	c := *card
+	for len(c.Meanings) > 1 && !n.fits(&c) {
+		c.Meanings = c.Meanings[:len(c.Meanings)-1]
+	}
+	if !n.fits(&c) {
+		return truncate(c.Text(), pushover.MaxMessageLength), false
+	}
+	if n.opts.HTML {
+		return c.HTML(), true
+	}
+
+	return c.Text(), false
+}
+
+func (n *pushoverNotifier) fits(card *Card) bool {
+	text := card.Text()
+	if n.opts.HTML {
+		text = card.HTML()
+	}
+
+	return utf8.RuneCountInString(text) <= pushover.MaxMessageLength
+}
+
+// truncate cuts the text to max characters marking the cut with ellipsis.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+
+	return string(runes[:max-1]) + "…"
+}
+
+// throttle returns card to send, nil is returned if card is postponed to the digest.
+func (n *pushoverNotifier) throttle(card *Card, now time.Time) *Card {
+	limits := n.client.Limits()
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if limits == nil || (limits.Remaining > n.opts.LowQuota && !limits.Exhausted(now)) {
+		n.digest = nil
+		return card
+	}
+	n.digest = mergeCards(n.digest, card)
+	interval := n.opts.DigestInterval
+	if interval == 0 {
+		interval = defaultDigestInterval
+	}
+	if limits.Exhausted(now) || now.Sub(n.lastDigest) < interval {
+		logrus.Infof("Pushover quota is low, %d pushes remaining, card is postponed to digest", limits.Remaining)
+		return nil
+	}
+	digest := n.digest
+	n.digest = nil
+	n.lastDigest = now
+
+	return digest
+}
+
+// mergeCards adds words of the card to the digest skipping duplicates.
+func mergeCards(digest *Card, card *Card) *Card {
+	if digest == nil {
+		digest = &Card{WordsetID: card.WordsetID, WordsetName: card.WordsetName}
+	}
+	if digest.WordsetID != card.WordsetID {
+		digest.WordsetName = "Digest"
+	}
+	seen := make(map[int]bool, len(digest.Meanings))
+	for _, m := range digest.Meanings {
+		seen[m.ID] = true
+	}
+	for _, m := range card.Meanings {
+		if len(digest.Meanings) >= maxDigestMeanings {
+			break
+		}
+		if !seen[m.ID] {
+			seen[m.ID] = true
+			digest.Meanings = append(digest.Meanings, m)
+		}
+	}
+
+	return digest
+}
+
+func (n *pushoverNotifier) watchReceipt(receipt string) {
+	ctx, cancel := context.WithTimeout(n.lifetime, n.opts.Expire+receiptWatchMargin)
+	defer cancel()
+	r, err := n.client.WaitReceipt(ctx, receipt, n.opts.Retry)
+	if n.opts.OnReceipt != nil {
+		n.opts.OnReceipt(receipt, r, err)
+		return
+	}
+	if err != nil {
+		logrus.Error("failed to get push receipt, got ", err)
+		return
+	}
+	if r.IsAcknowledged() {
+		logrus.Infof("Push %s acknowledged by %s", receipt, r.AcknowledgedBy)
+		return
+	}
+	logrus.Infof("Push %s expired without acknowledgement", receipt)
+}
+
+// image downloads image of the first card word having one, nil is returned if there is no image.
+func (n *pushoverNotifier) image(ctx context.Context, card *Card) (*pushover.Attachment, error) {
+	var imageURL string
+	for _, m := range card.Meanings {
+		if imageURL = m.ImageURL(); imageURL != "" {
+			break
+		}
+	}
+	if imageURL == "" {
+		return nil, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.WithStack(fmt.Errorf("failed to get image %s, got status %d", imageURL, resp.StatusCode))
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(data) > maxImageSize {
+		return nil, errors.Errorf("image %s is too big", imageURL)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	return &pushover.Attachment{
+		Name:        path.Base(req.URL.Path),
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// defaultDailyGoal is a count of words to review daily used if goal is not set.
+const defaultDailyGoal = 20
+
+// NewPushoverGlance returns function showing review stats on Pushover widgets,
+// percent of the daily goal is shown along with today's count and the streak.
+// Days are counted in the location of now.
+func NewPushoverGlance(client pushover.Client, dailyGoal int) func(ctx context.Context, stats storage.Stats, now time.Time) error {
+	if dailyGoal <= 0 {
+		dailyGoal = defaultDailyGoal
+	}
+	return func(ctx context.Context, stats storage.Stats, now time.Time) error {
+		reviewed := stats.ReviewedToday(now)
+		percent := reviewed * 100 / dailyGoal
+		if percent > 100 {
+			percent = 100
+		}
+		return client.UpdateGlance(ctx, &pushover.Glance{
+			Title:   "Skyeng words",
+			Text:    fmt.Sprintf("%d of %d words today", reviewed, dailyGoal),
+			Subtext: fmt.Sprintf("Streak: %d days", stats.CurrentStreak(now)),
+			Count:   &reviewed,
+			Percent: &percent,
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/pushover"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+)
+
+type fakePushover struct {
+	pushover.MockPushover
+	limits  *pushover.Limits
+	sent    []*pushover.Message
+	receipt string
+}
+
+func (p *fakePushover) Send(ctx context.Context, msg *pushover.Message) (*pushover.Response, error) {
+	p.sent = append(p.sent, msg)
+	return &pushover.Response{Status: 1, Receipt: p.receipt}, nil
+}
+
+func (p *fakePushover) WaitReceipt(ctx context.Context, receipt string, interval time.Duration) (*pushover.Receipt, error) {
+	select {
+	case <-time.After(10 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return p.MockPushover.WaitReceipt(ctx, receipt, interval)
+}
+
+func (p *fakePushover) Limits() *pushover.Limits {
+	return p.limits
+}
+
+func card(id int, text string) *Card {
+	return &Card{WordsetID: 1, WordsetName: "daily", Meanings: []skyeng.Meaning{{ID: id, Text: text}}}
+}
+
+func TestPushoverDigestOnLowQuota(t *testing.T) {
+	client := &fakePushover{}
+	n := NewPushover(context.Background(), client, PushoverOptions{LowQuota: 10, DigestInterval: time.Hour}).(*pushoverNotifier)
+	now := time.Now()
+
+	if n.throttle(card(1, "decision"), now) == nil {
+		t.Fatal("card is postponed while quota is unknown")
+	}
+
+	client.limits = &pushover.Limits{Limit: 100, Remaining: 5, Reset: now.Add(24 * time.Hour)}
+	digest := n.throttle(card(1, "decision"), now)
+	if digest == nil || len(digest.Meanings) != 1 {
+		t.Fatalf("expected first digest to be sent, got %+v", digest)
+	}
+	if n.throttle(card(2, "postpone"), now.Add(time.Minute)) != nil {
+		t.Fatal("expected card to be postponed")
+	}
+	if n.throttle(card(2, "postpone"), now.Add(2*time.Minute)) != nil {
+		t.Fatal("expected card to be postponed")
+	}
+	digest = n.throttle(card(3, "deadline"), now.Add(time.Hour))
+	if digest == nil || len(digest.Meanings) != 2 {
+		t.Fatalf("expected digest of 2 words, got %+v", digest)
+	}
+
+	client.limits = &pushover.Limits{Limit: 100, Remaining: 0, Reset: now.Add(24 * time.Hour)}
+	if n.throttle(card(4, "decline"), now.Add(3*time.Hour)) != nil {
+		t.Fatal("expected no pushes while quota is exhausted")
+	}
+}
+
+func TestPushoverLargeCard(t *testing.T) {
+	client := &fakePushover{}
+	n := NewPushover(context.Background(), client, PushoverOptions{HTML: true})
+	large := &Card{WordsetID: 1, WordsetName: "daily"}
+	for i := 1; i <= 100; i++ {
+		large.Meanings = append(large.Meanings, skyeng.Meaning{
+			ID:          i,
+			Text:        fmt.Sprintf("word%d", i),
+			Translation: skyeng.Translation{Text: strings.Repeat("перевод ", 30)},
+		})
+	}
+
+	for i := 0; i < 2; i++ {
+		err := n.Notify(context.Background(), large)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(client.sent) != 2 {
+		t.Fatalf("expected 2 pushes, got %d", len(client.sent))
+	}
+	for _, msg := range client.sent {
+		if utf8.RuneCountInString(msg.Message) > pushover.MaxMessageLength {
+			t.Errorf("message exceeds Pushover limit, got %d characters", utf8.RuneCountInString(msg.Message))
+		}
+	}
+	if !strings.Contains(client.sent[0].Message, "<b>word1</b>") || strings.Contains(client.sent[1].Message, "<b>word1</b>") {
+		t.Errorf("expected the next push to continue with the following words, got %q", client.sent[1].Message)
+	}
+}
+
+func TestPushoverReceipt(t *testing.T) {
+	client := &fakePushover{receipt: "rcpt"}
+	receipts := make(chan *pushover.Receipt, 1)
+	n := NewPushover(context.Background(), client, PushoverOptions{
+		Priority: pushover.PriorityEmergency,
+		Retry:    time.Minute,
+		Expire:   time.Hour,
+		OnReceipt: func(receipt string, r *pushover.Receipt, err error) {
+			if err != nil || receipt != "rcpt" {
+				t.Errorf("unexpected receipt %s, got %v", receipt, err)
+			}
+			receipts <- r
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	err := n.Notify(ctx, card(1, "decision"))
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-receipts:
+		if r == nil || !r.IsAcknowledged() {
+			t.Errorf("expected acknowledged receipt, got %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("receipt is not passed back")
+	}
+}
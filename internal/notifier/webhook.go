@@ -0,0 +1,259 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Webhook request headers, signature is HMAC-SHA256 of the timestamp, a dot and the body.
+const (
+	WebhookSignatureHeader = "X-Skyeng-Signature"
+	WebhookTimestampHeader = "X-Skyeng-Timestamp"
+	WebhookDeliveryHeader  = "X-Skyeng-Delivery"
+)
+
+// webhookSchemaVersion is increased on incompatible payload changes only.
+const webhookSchemaVersion = 1
+
+// WebhookPayload is JSON posted to webhooks for every card, fields are never renamed or removed.
+type WebhookPayload struct {
+	Version int            `json:"version"`
+	Event   string         `json:"event"`
+	SentAt  time.Time      `json:"sent_at"`
+	Wordset WebhookWordset `json:"wordset"`
+	Words   []WebhookWord  `json:"words"`
+}
+
+// WebhookWordset represents wordset of the card.
+type WebhookWordset struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebhookWord represents word of the card.
+type WebhookWord struct {
+	MeaningID     int      `json:"meaning_id"`
+	Text          string   `json:"text"`
+	Transcription string   `json:"transcription"`
+	Translation   string   `json:"translation"`
+	PartOfSpeech  string   `json:"part_of_speech"`
+	SoundURL      string   `json:"sound_url"`
+	ImageURL      string   `json:"image_url"`
+	Examples      []string `json:"examples"`
+}
+
+// WebhookEndpoint represents webhook receiver, requests are signed if secret is set.
+type WebhookEndpoint struct {
+	URL    string
+	Secret string
+}
+
+// WebhookOptions represents webhook delivery parameters, zero values mean defaults.
+type WebhookOptions struct {
+	Endpoints []WebhookEndpoint
+	// MaxAttempts is a total count of delivery attempts to every endpoint.
+	MaxAttempts int
+	// BaseDelay is a delay before the first retry, it doubles on every next retry.
+	BaseDelay time.Duration
+	Timeout   time.Duration
+}
+
+// NewWebhook returns Notifier posting cards to webhook endpoints, endpoints without secret are warned about.
+func NewWebhook(opts WebhookOptions) Notifier {
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay == 0 {
+		opts.BaseDelay = time.Second
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	for _, endpoint := range opts.Endpoints {
+		if endpoint.Secret == "" {
+			logrus.Warnf("Webhook %s has no secret, its requests are not signed", endpoint.URL)
+		}
+	}
+	return &webhookNotifier{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+type webhookNotifier struct {
+	opts   WebhookOptions
+	client *http.Client
+}
+
+// Notify posts card to all endpoints concurrently, failure of one endpoint does not stop others.
+// Only the first attempt is awaited, temporary failures are retried in background so slow
+// endpoints do not hold the sender.
+func (n *webhookNotifier) Notify(ctx context.Context, card *Card) error {
+	body, err := json.Marshal(NewWebhookPayload(card, time.Now()))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	delivery, err := deliveryID()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(n.opts.Endpoints))
+	for _, endpoint := range n.opts.Endpoints {
+		wg.Add(1)
+		go func(endpoint WebhookEndpoint) {
+			defer wg.Done()
+			retryable, err := n.post(ctx, endpoint, delivery, body)
+			if err != nil && retryable && n.opts.MaxAttempts > 1 {
+				logrus.Warnf("Webhook %s failed, retrying in background, got %s", endpoint.URL, err)
+				go n.retry(endpoint, delivery, body)
+				return
+			}
+			if err != nil {
+				errCh <- errors.Wrapf(err, "failed to deliver webhook to %s", endpoint.URL)
+			}
+		}(endpoint)
+	}
+	wg.Wait()
+	close(errCh)
+	var failed int
+	var lastErr error
+	for err := range errCh {
+		logrus.Error(err)
+		failed++
+		lastErr = err
+	}
+	if failed > 0 {
+		return errors.Wrapf(lastErr, "%d of %d webhooks failed", failed, len(n.opts.Endpoints))
+	}
+
+	return nil
+}
+
+// retry redelivers body after failed first attempt, retries are limited by the total time of all
+// attempts and delays rather than the send context.
+func (n *webhookNotifier) retry(endpoint WebhookEndpoint, delivery string, body []byte) {
+	total := n.opts.BaseDelay<<uint(n.opts.MaxAttempts) + time.Duration(n.opts.MaxAttempts)*n.opts.Timeout
+	ctx, cancel := context.WithTimeout(context.Background(), total)
+	defer cancel()
+	err := n.deliver(ctx, endpoint, delivery, body, 2)
+	if err != nil {
+		logrus.Error(errors.Wrapf(err, "failed to deliver webhook to %s", endpoint.URL))
+	}
+}
+
+// deliver posts body to endpoint starting from the attempt, network errors, 429 and 5xx responses are retried.
+func (n *webhookNotifier) deliver(ctx context.Context, endpoint WebhookEndpoint, delivery string, body []byte, first int) error {
+	var err error
+	for attempt := first; attempt <= n.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(n.opts.BaseDelay << uint(attempt-2)):
+			case <-ctx.Done():
+				return errors.WithStack(ctx.Err())
+			}
+		}
+		var retryable bool
+		retryable, err = n.post(ctx, endpoint, delivery, body)
+		if err == nil || !retryable {
+			return err
+		}
+		logrus.Warnf("Webhook %s failed, attempt %d of %d, got %s", endpoint.URL, attempt, n.opts.MaxAttempts, err)
+	}
+
+	return err
+}
+
+func (n *webhookNotifier) post(ctx context.Context, endpoint WebhookEndpoint, delivery string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookDeliveryHeader, delivery)
+	if endpoint.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, timestamp, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+
+	return retryable, errors.WithStack(fmt.Errorf("webhook responded with status %d", resp.StatusCode))
+}
+
+// NewWebhookPayload returns webhook payload of the card.
+func NewWebhookPayload(card *Card, sentAt time.Time) *WebhookPayload {
+	payload := &WebhookPayload{
+		Version: webhookSchemaVersion,
+		Event:   "card",
+		SentAt:  sentAt.UTC(),
+		Wordset: WebhookWordset{ID: card.WordsetID, Name: card.WordsetName},
+		Words:   make([]WebhookWord, 0, len(card.Meanings)),
+	}
+	for _, m := range card.Meanings {
+		examples := make([]string, 0, len(m.Examples))
+		for _, e := range m.Examples {
+			examples = append(examples, e.Text)
+		}
+		payload.Words = append(payload.Words, WebhookWord{
+			MeaningID:     m.ID,
+			Text:          m.Text,
+			Transcription: m.Transcription,
+			Translation:   m.Translation.Text,
+			PartOfSpeech:  m.PartOfSpeech(),
+			SoundURL:      m.SoundURL,
+			ImageURL:      m.ImageURL(),
+			Examples:      examples,
+		})
+	}
+
+	return payload
+}
+
+// SignWebhook returns signature header value of the webhook request.
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook reports whether signature header value matches the webhook request.
+func VerifyWebhook(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature))
+}
+
+func deliveryID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
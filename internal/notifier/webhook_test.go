@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+)
+
+func TestWebhookNotify(t *testing.T) {
+	var failures int32 = 2
+	payloads := make(chan *WebhookPayload, 10)
+	signed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if !VerifyWebhook("secret", req.Header.Get(WebhookTimestampHeader), body, req.Header.Get(WebhookSignatureHeader)) {
+			t.Error("invalid webhook signature")
+		}
+		var payload WebhookPayload
+		err := json.Unmarshal(body, &payload)
+		if err != nil {
+			t.Error(err)
+		}
+		payloads <- &payload
+	}))
+	defer signed.Close()
+	var rejected int32
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&rejected, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+
+	n := NewWebhook(WebhookOptions{
+		Endpoints:   []WebhookEndpoint{{URL: signed.URL, Secret: "secret"}, {URL: rejecting.URL}},
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+	})
+	card := &Card{WordsetID: 7, WordsetName: "daily", Meanings: []skyeng.Meaning{{
+		ID:            1,
+		Text:          "decision",
+		Transcription: "dɪˈsɪʒn",
+		Translation:   skyeng.Translation{Text: "решение"},
+		Examples:      []skyeng.Example{{Text: "It was a hard decision."}},
+	}}}
+	err := n.Notify(context.Background(), card)
+	if err == nil {
+		t.Fatal("expected rejecting endpoint error")
+	}
+	if rejected != 1 {
+		t.Errorf("expected rejected webhook not to be retried, got %d requests", rejected)
+	}
+
+	select {
+	case payload := <-payloads:
+		if payload.Version != 1 || payload.Wordset.ID != 7 || len(payload.Words) != 1 {
+			t.Fatalf("unexpected payload %+v", payload)
+		}
+		word := payload.Words[0]
+		if word.MeaningID != 1 || word.Translation != "решение" || len(word.Examples) != 1 {
+			t.Errorf("unexpected word %+v", word)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook is not delivered after retries")
+	}
+}
+
+func TestWebhookRetriesDoNotBlock(t *testing.T) {
+	var requests int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	n := NewWebhook(WebhookOptions{
+		Endpoints:   []WebhookEndpoint{{URL: failing.URL, Secret: "secret"}},
+		MaxAttempts: 3,
+		BaseDelay:   time.Hour,
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- n.Notify(context.Background(), &Card{WordsetID: 7})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected temporary failure to be retried in background, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notify is blocked by retries")
+	}
+	if count := atomic.LoadInt32(&requests); count != 1 {
+		t.Errorf("expected single awaited attempt, got %d requests", count)
+	}
+}
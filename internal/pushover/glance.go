@@ -0,0 +1,72 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// maxGlanceFieldLen is a Pushover limit of glance text fields.
+const maxGlanceFieldLen = 100
+
+// Glance represents data shown by Pushover widgets and watch complications, empty fields are not updated.
+type Glance struct {
+	Title   string
+	Text    string
+	Subtext string
+	Count   *int
+	// Percent from 0 to 100.
+	Percent *int
+	// Device overrides device of the client.
+	Device string
+}
+
+// UpdateGlance updates widgets data without sending notification.
+func (c *client) UpdateGlance(ctx context.Context, g *Glance) error {
+	for _, field := range []string{g.Title, g.Text, g.Subtext} {
+		if len([]rune(field)) > maxGlanceFieldLen {
+			return errors.Errorf("glance field %q exceeds %d characters", field, maxGlanceFieldLen)
+		}
+	}
+	if g.Percent != nil && (*g.Percent < 0 || *g.Percent > 100) {
+		return errors.Errorf("glance percent %d is out of range", *g.Percent)
+	}
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fields := map[string]string{
+		"token":   c.token,
+		"user":    c.user,
+		"device":  c.device,
+		"title":   g.Title,
+		"text":    g.Text,
+		"subtext": g.Subtext,
+	}
+	if g.Device != "" {
+		fields["device"] = g.Device
+	}
+	if g.Count != nil {
+		fields["count"] = strconv.Itoa(*g.Count)
+	}
+	if g.Percent != nil {
+		fields["percent"] = strconv.Itoa(*g.Percent)
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		err := w.WriteField(name, value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var resp Response
+	return c.do(ctx, "POST", c.endpoint+"/1/glances.json", w.FormDataContentType(), body.Bytes(), &resp)
+}
@@ -0,0 +1,80 @@
+package pushover
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCredentials = errors.New("pushover token or user is invalid")
+var ErrQuotaExceeded = errors.New("pushover quota exceeded")
+var ErrUnavailable = errors.New("pushover unavailable")
+
+// Limits represents monthly message quota of the Pushover application.
+type Limits struct {
+	Limit     int
+	Remaining int
+	// Reset is a time when the quota is restored.
+	Reset time.Time
+}
+
+// Exhausted reports whether quota is over and is not restored yet.
+func (l *Limits) Exhausted(now time.Time) bool {
+	return l.Remaining <= 0 && now.Before(l.Reset)
+}
+
+// parseLimits returns quota reported in X-Limit-App headers, nil is returned if headers are absent.
+func parseLimits(header http.Header) *Limits {
+	limit, err := strconv.Atoi(header.Get("X-Limit-App-Limit"))
+	if err != nil {
+		return nil
+	}
+	remaining, err := strconv.Atoi(header.Get("X-Limit-App-Remaining"))
+	if err != nil {
+		return nil
+	}
+	reset, err := strconv.ParseInt(header.Get("X-Limit-App-Reset"), 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &Limits{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}
+}
+
+// StatusError represents unsuccessful Pushover response.
+type StatusError struct {
+	StatusCode int
+	Errors     []string
+	// InvalidCredentials is set if Pushover rejected application token or user key.
+	InvalidCredentials bool
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("pushover responded with status %d: %s", e.StatusCode, strings.Join(e.Errors, ", "))
+}
+
+// Unwrap maps response status to the sentinel error.
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.InvalidCredentials:
+		return ErrInvalidCredentials
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrQuotaExceeded
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrUnavailable
+	}
+
+	return nil
+}
+
+// Temporary reports whether request may succeed on retry, 4xx responses are permanent.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
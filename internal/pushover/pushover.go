@@ -2,61 +2,400 @@ package pushover
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Message priorities.
+const (
+	PriorityLowest    = -2
+	PriorityLow       = -1
+	PriorityNormal    = 0
+	PriorityHigh      = 1
+	PriorityEmergency = 2
 )
 
+// MaxMessageLength is a Pushover limit of the message length in characters.
+const MaxMessageLength = 1024
+
+const (
+	// maxTitleLength is a Pushover limit of the title length in characters.
+	maxTitleLength = 250
+	// maxAttachmentSize is a Pushover limit of the attachment size.
+	maxAttachmentSize = 2621440
+	// minEmergencyRetry is a Pushover limit of emergency retry interval.
+	minEmergencyRetry = 30 * time.Second
+	// maxEmergencyExpire is a Pushover limit of emergency expiration.
+	maxEmergencyExpire = 3 * time.Hour
+	// minReceiptPollInterval is a polling interval recommended by Pushover.
+	minReceiptPollInterval = 5 * time.Second
+	// maxAttempts is a count of attempts to deliver GET request on network errors and 5xx responses.
+	maxAttempts = 3
+)
+
+// Message represents Pushover message.
+type Message struct {
+	Title   string
+	Message string
+	// HTML enables HTML formatting of the message.
+	HTML     bool
+	Priority int
+	Sound    string
+	URL      string
+	URLTitle string
+	// TTL is a time after which the message is deleted from devices.
+	TTL time.Duration
+	// Device overrides device of the client.
+	Device string
+	// Retry and Expire are required for emergency priority.
+	Retry      time.Duration
+	Expire     time.Duration
+	Attachment *Attachment
+}
+
+// Attachment represents image attached to the message.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Response represents Pushover response to the sent message.
+type Response struct {
+	Status  int      `json:"status"`
+	Request string   `json:"request"`
+	Receipt string   `json:"receipt"`
+	Errors  []string `json:"errors"`
+	// Token and User are "invalid" if Pushover rejected them.
+	Token string `json:"token"`
+	User  string `json:"user"`
+}
+
+// Receipt represents acknowledgement status of emergency priority message.
+type Receipt struct {
+	Status               int    `json:"status"`
+	Acknowledged         int    `json:"acknowledged"`
+	AcknowledgedAt       int64  `json:"acknowledged_at"`
+	AcknowledgedBy       string `json:"acknowledged_by"`
+	AcknowledgedByDevice string `json:"acknowledged_by_device"`
+	LastDeliveredAt      int64  `json:"last_delivered_at"`
+	Expired              int    `json:"expired"`
+	ExpiresAt            int64  `json:"expires_at"`
+}
+
+// IsAcknowledged reports whether user acknowledged the message.
+func (r *Receipt) IsAcknowledged() bool {
+	return r.Acknowledged == 1
+}
+
+// IsExpired reports whether message stopped alerting without acknowledgement.
+func (r *Receipt) IsExpired() bool {
+	return r.Expired == 1
+}
+
 // Client represents Pushover interface.
 type Client interface {
 	SendPush(title string, message string) error
+	Send(ctx context.Context, msg *Message) (*Response, error)
+	GetReceipt(ctx context.Context, receipt string) (*Receipt, error)
+	// WaitReceipt polls receipt until the message is acknowledged or expired.
+	WaitReceipt(ctx context.Context, receipt string, interval time.Duration) (*Receipt, error)
+	// UpdateGlance updates widgets data without sending notification.
+	UpdateGlance(ctx context.Context, g *Glance) error
+	// Limits returns application quota reported by the last response, nil is returned if it is unknown.
+	Limits() *Limits
+}
+
+// Option configures client.
+type Option func(c *client)
+
+// WithEndpoint overrides Pushover api endpoint.
+func WithEndpoint(endpoint string) Option {
+	return func(c *client) {
+		c.endpoint = endpoint
+	}
 }
 
 // NewClient returns Client compatible functionality.
-func NewClient(token string, user string, device string) Client {
-	return &client{
-		endpoint: "https://api.pushover.net",
-		token:    token,
-		user:     user,
-		device:   device,
+func NewClient(token string, user string, device string, opts ...Option) Client {
+	c := &client{
+		endpoint:   "https://api.pushover.net",
+		token:      token,
+		user:       user,
+		device:     device,
+		client:     &http.Client{},
+		retryDelay: time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
 type client struct {
-	endpoint string
-	token    string
-	user     string
-	device   string
+	endpoint   string
+	token      string
+	user       string
+	device     string
+	client     *http.Client
+	retryDelay time.Duration
+
+	mx     sync.Mutex
+	limits *Limits
+	// rejected keeps error of rejected credentials, requests are not sent anymore once it is set.
+	rejected error
 }
 
 // SendPush sends push to Pushover.
-func (c client) SendPush(title string, message string) error {
-	msg := map[string]interface{}{
+func (c *client) SendPush(title string, message string) error {
+	_, err := c.Send(context.Background(), &Message{
+		Title:   title,
+		Message: message,
+	})
+
+	return err
+}
+
+// Send sends message to Pushover, the response contains receipt for emergency priority messages.
+func (c *client) Send(ctx context.Context, msg *Message) (*Response, error) {
+	err := validate(msg)
+	if err != nil {
+		return nil, err
+	}
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fields := map[string]string{
 		"token":   c.token,
 		"user":    c.user,
+		"title":   msg.Title,
+		"message": msg.Message,
 		"device":  c.device,
-		"title":   title,
-		"message": message,
+	}
+	if msg.Device != "" {
+		fields["device"] = msg.Device
+	}
+	if msg.HTML {
+		fields["html"] = "1"
+	}
+	if msg.Priority != PriorityNormal {
+		fields["priority"] = strconv.Itoa(msg.Priority)
+	}
+	if msg.Priority == PriorityEmergency {
+		fields["retry"] = strconv.Itoa(int(msg.Retry.Seconds()))
+		fields["expire"] = strconv.Itoa(int(msg.Expire.Seconds()))
+	}
+	if msg.Sound != "" {
+		fields["sound"] = msg.Sound
+	}
+	if msg.URL != "" {
+		fields["url"] = msg.URL
+		fields["url_title"] = msg.URLTitle
+	}
+	if msg.TTL > 0 {
+		fields["ttl"] = strconv.Itoa(int(msg.TTL.Seconds()))
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		err = w.WriteField(name, value)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if msg.Attachment != nil {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="attachment"; filename=%q`, msg.Attachment.Name))
+		header.Set("Content-Type", msg.Attachment.ContentType)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		_, err = part.Write(msg.Attachment.Data)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
 
-	b, err := json.Marshal(msg)
+	var resp Response
+	err = c.do(ctx, "POST", c.endpoint+"/1/messages.json", w.FormDataContentType(), body.Bytes(), &resp)
 	if err != nil {
-		return errors.WithStack(err)
+		return nil, err
 	}
+	logrus.Info("Push sent: " + msg.Message)
+
+	return &resp, nil
+}
 
-	resp, err := http.Post(c.endpoint+"/1/messages.json", "application/json", bytes.NewBuffer(b))
+// GetReceipt returns acknowledgement status of emergency priority message.
+func (c *client) GetReceipt(ctx context.Context, receipt string) (*Receipt, error) {
+	receiptURL := fmt.Sprintf("%s/1/receipts/%s.json?token=%s", c.endpoint, receipt, c.token)
+	var resp Receipt
+	err := c.do(ctx, "GET", receiptURL, "", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// WaitReceipt polls receipt until the message is acknowledged or expired.
+func (c *client) WaitReceipt(ctx context.Context, receipt string, interval time.Duration) (*Receipt, error) {
+	if interval < minReceiptPollInterval {
+		interval = minReceiptPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r, err := c.GetReceipt(ctx, receipt)
+		if err != nil {
+			return nil, err
+		}
+		if r.IsAcknowledged() || r.IsExpired() {
+			return r, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return r, errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+// Limits returns application quota reported by the last response, nil is returned if it is unknown.
+func (c *client) Limits() *Limits {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.limits == nil {
+		return nil
+	}
+	limits := *c.limits
+
+	return &limits
+}
+
+// do sends request retrying network errors and 5xx responses of GET requests. Other requests are sent once
+// since they are not idempotent, repeated message send may deliver duplicate push. 4xx responses are not retried.
+func (c *client) do(ctx context.Context, method string, url string, contentType string, body []byte, v interface{}) error {
+	c.mx.Lock()
+	rejected := c.rejected
+	c.mx.Unlock()
+	if rejected != nil {
+		return rejected
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(c.retryDelay * time.Duration(attempt-1)):
+			case <-ctx.Done():
+				return errors.WithStack(ctx.Err())
+			}
+		}
+		err = c.doOnce(ctx, method, url, contentType, body, v)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.Temporary() {
+			if statusErr.InvalidCredentials {
+				c.mx.Lock()
+				c.rejected = err
+				c.mx.Unlock()
+			}
+			return err
+		}
+		if err == nil || ctx.Err() != nil || method != http.MethodGet {
+			return err
+		}
+		logrus.Warnf("Pushover request failed, attempt %d of %d, got %s", attempt, maxAttempts, err)
+	}
+
+	return err
+}
+
+func (c *client) doOnce(ctx context.Context, method string, url string, contentType string, body []byte, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(ErrUnavailable, err.Error())
+	}
 	defer resp.Body.Close()
+	if limits := parseLimits(resp.Header); limits != nil {
+		c.mx.Lock()
+		c.limits = limits
+		c.mx.Unlock()
+	}
 	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(ErrUnavailable, err.Error())
+	}
 	if resp.StatusCode != http.StatusOK {
-		return errors.WithStack(fmt.Errorf("failed to send push message, got status %d, message %s", resp.StatusCode, respBody))
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+		var errResp Response
+		if json.Unmarshal(respBody, &errResp) == nil {
+			statusErr.Errors = errResp.Errors
+			statusErr.InvalidCredentials = errResp.Token == "invalid" || errResp.User == "invalid"
+		}
+		if len(statusErr.Errors) == 0 {
+			statusErr.Errors = []string{string(respBody)}
+		}
+		return errors.WithStack(statusErr)
 	}
-	logrus.Info("Push sent: " + message)
+	err = json.Unmarshal(respBody, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func validate(msg *Message) error {
+	if msg.Message == "" {
+		return errors.New("message is required")
+	}
+	if utf8.RuneCountInString(msg.Message) > MaxMessageLength {
+		return errors.Errorf("message exceeds %d characters", MaxMessageLength)
+	}
+	if utf8.RuneCountInString(msg.Title) > maxTitleLength {
+		return errors.Errorf("title exceeds %d characters", maxTitleLength)
+	}
+	if msg.Priority < PriorityLowest || msg.Priority > PriorityEmergency {
+		return errors.Errorf("unknown priority %d", msg.Priority)
+	}
+	if msg.Priority == PriorityEmergency {
+		if msg.Retry < minEmergencyRetry {
+			return errors.Errorf("emergency retry must be at least %s", minEmergencyRetry)
+		}
+		if msg.Expire <= 0 || msg.Expire > maxEmergencyExpire {
+			return errors.Errorf("emergency expire must be within %s", maxEmergencyExpire)
+		}
+	}
+	if msg.Attachment != nil && len(msg.Attachment.Data) > maxAttachmentSize {
+		return errors.Errorf("attachment exceeds %d bytes", maxAttachmentSize)
+	}
+
 	return nil
 }
 
@@ -68,3 +407,30 @@ func (t MockPushover) SendPush(title string, message string) error {
 	fmt.Printf("Push sended: title %q, message %q \n", title, message)
 	return nil
 }
+
+// Send is fake pushover method.
+func (t MockPushover) Send(ctx context.Context, msg *Message) (*Response, error) {
+	fmt.Printf("Push sended: title %q, message %q \n", msg.Title, msg.Message)
+	return &Response{Status: 1}, nil
+}
+
+// GetReceipt is fake pushover method, messages are always acknowledged.
+func (t MockPushover) GetReceipt(ctx context.Context, receipt string) (*Receipt, error) {
+	return &Receipt{Status: 1, Acknowledged: 1}, nil
+}
+
+// WaitReceipt is fake pushover method, messages are always acknowledged.
+func (t MockPushover) WaitReceipt(ctx context.Context, receipt string, interval time.Duration) (*Receipt, error) {
+	return t.GetReceipt(ctx, receipt)
+}
+
+// UpdateGlance is fake pushover method.
+func (t MockPushover) UpdateGlance(ctx context.Context, g *Glance) error {
+	fmt.Printf("Glance updated: title %q, text %q \n", g.Title, g.Text)
+	return nil
+}
+
+// Limits is fake pushover method, quota is unknown.
+func (t MockPushover) Limits() *Limits {
+	return nil
+}
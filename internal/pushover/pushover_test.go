@@ -0,0 +1,199 @@
+package pushover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientSend(t *testing.T) {
+	var form map[string][]string
+	var attachment []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		err := req.ParseMultipartForm(1 << 20)
+		if err != nil {
+			t.Error(err)
+		}
+		form = req.MultipartForm.Value
+		if files := req.MultipartForm.File["attachment"]; len(files) == 1 {
+			f, _ := files[0].Open()
+			attachment, _ = ioutil.ReadAll(f)
+			f.Close()
+		}
+		_ = json.NewEncoder(w).Encode(Response{Status: 1, Request: "req", Receipt: "rcpt"})
+	}))
+	defer srv.Close()
+	c := NewClient("token", "user", "phone", WithEndpoint(srv.URL))
+
+	resp, err := c.Send(context.Background(), &Message{
+		Title:      "daily",
+		Message:    "<b>decision</b>",
+		HTML:       true,
+		Priority:   PriorityEmergency,
+		Sound:      "magic",
+		URL:        "https://skyeng.ru",
+		URLTitle:   "skyeng",
+		TTL:        time.Hour,
+		Device:     "watch",
+		Retry:      time.Minute,
+		Expire:     time.Hour,
+		Attachment: &Attachment{Name: "decision.png", ContentType: "image/png", Data: []byte("png")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Receipt != "rcpt" {
+		t.Errorf("expected receipt, got %+v", resp)
+	}
+	expected := map[string]string{
+		"token": "token", "user": "user", "device": "watch", "html": "1", "priority": "2", "retry": "60",
+		"expire": "3600", "sound": "magic", "url": "https://skyeng.ru", "url_title": "skyeng", "ttl": "3600",
+	}
+	for name, value := range expected {
+		if got := form[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("expected %s=%s, got %v", name, value, got)
+		}
+	}
+	if string(attachment) != "png" {
+		t.Errorf("unexpected attachment %q", attachment)
+	}
+
+	_, err = c.Send(context.Background(), &Message{Message: "decision", Priority: PriorityEmergency})
+	if err == nil {
+		t.Error("expected emergency validation error")
+	}
+	_, err = c.Send(context.Background(), &Message{Message: strings.Repeat("я", MaxMessageLength+1)})
+	if err == nil {
+		t.Error("expected message length validation error")
+	}
+}
+
+func TestClientWaitReceipt(t *testing.T) {
+	var mx sync.Mutex
+	var polls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/1/receipts/rcpt.json" || req.URL.Query().Get("token") != "token" {
+			t.Errorf("unexpected request %s", req.URL)
+		}
+		mx.Lock()
+		polls++
+		r := Receipt{Status: 1}
+		if polls == 2 {
+			r.Acknowledged = 1
+			r.AcknowledgedBy = "user"
+		}
+		mx.Unlock()
+		_ = json.NewEncoder(w).Encode(r)
+	}))
+	defer srv.Close()
+	c := &client{endpoint: srv.URL, token: "token", client: srv.Client()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r, err := c.WaitReceipt(ctx, "rcpt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsAcknowledged() || r.AcknowledgedBy != "user" {
+		t.Errorf("expected acknowledged receipt, got %+v", r)
+	}
+}
+
+func TestClientLimitsAndErrors(t *testing.T) {
+	var mx sync.Mutex
+	var requests int
+	statuses := []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK, http.StatusBadRequest}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mx.Lock()
+		status := statuses[requests]
+		requests++
+		mx.Unlock()
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "42")
+		w.Header().Set("X-Limit-App-Reset", "1893456000")
+		w.WriteHeader(status)
+		if status == http.StatusBadRequest {
+			_ = json.NewEncoder(w).Encode(Response{Token: "invalid", Errors: []string{"application token is invalid"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{Status: 1})
+	}))
+	defer srv.Close()
+	c := &client{endpoint: srv.URL, token: "token", user: "user", client: srv.Client(), retryDelay: time.Millisecond}
+
+	if c.Limits() != nil {
+		t.Fatal("expected unknown limits")
+	}
+	err := c.SendPush("daily", "decision")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected message send not to be retried, got %d requests", requests)
+	}
+	_, err = c.GetReceipt(context.Background(), "rcpt")
+	if err != nil {
+		t.Fatalf("expected receipt success after retry, got %v", err)
+	}
+	limits := c.Limits()
+	if limits == nil || limits.Limit != 10000 || limits.Remaining != 42 || limits.Reset.Unix() != 1893456000 {
+		t.Fatalf("unexpected limits %+v", limits)
+	}
+
+	err = c.SendPush("daily", "decision")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	err = c.SendPush("daily", "decision")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if requests != 4 {
+		t.Errorf("expected rejected credentials not to be retried, got %d requests", requests)
+	}
+}
+
+func TestClientUpdateGlance(t *testing.T) {
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/1/glances.json" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		err := req.ParseMultipartForm(1 << 20)
+		if err != nil {
+			t.Error(err)
+		}
+		form = req.MultipartForm.Value
+		_ = json.NewEncoder(w).Encode(Response{Status: 1})
+	}))
+	defer srv.Close()
+	c := NewClient("token", "user", "watch", WithEndpoint(srv.URL))
+
+	count, percent := 12, 60
+	err := c.UpdateGlance(context.Background(), &Glance{Title: "Skyeng", Text: "12 words", Count: &count, Percent: &percent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"device": "watch", "title": "Skyeng", "text": "12 words", "count": "12", "percent": "60"}
+	for name, value := range expected {
+		if got := form[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("expected %s=%s, got %v", name, value, got)
+		}
+	}
+	if _, ok := form["subtext"]; ok {
+		t.Error("empty subtext is sent")
+	}
+
+	percent = 150
+	err = c.UpdateGlance(context.Background(), &Glance{Percent: &percent})
+	if err == nil {
+		t.Error("expected percent validation error")
+	}
+}
@@ -0,0 +1,125 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxCronLookup limits search of the next cron time for expressions never matching, like "0 0 31 2 *".
+const maxCronLookup = 5 * 366 * 24 * time.Hour
+
+// cron is schedule of five fields cron expression: minute, hour, day of month, month and day of week.
+type cron struct {
+	loc     *time.Location
+	minutes map[int]bool
+	hours   map[int]bool
+	dom     map[int]bool
+	months  map[int]bool
+	dow     map[int]bool
+	// domAny and dowAny are set for day fields starting with "*", like "*" or "*/2", if both day fields
+	// are restricted either of them should match.
+	domAny bool
+	dowAny bool
+}
+
+func parseCron(fields []string, loc *time.Location) (Schedule, error) {
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]map[int]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse cron field %q", field)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7.
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	return &cron{
+		loc:     loc,
+		minutes: sets[0],
+		hours:   sets[1],
+		dom:     sets[2],
+		months:  sets[3],
+		dow:     sets[4],
+		domAny:  strings.HasPrefix(fields[2], "*"),
+		dowAny:  strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField parses comma separated list of "*", "a", "a-b" items with optional "/step".
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.Errorf("invalid step %q", item[i+1:])
+			}
+			item = item[:i]
+		}
+		from, to := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Errorf("invalid value %q", bounds[0])
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, errors.Errorf("range %d-%d is out of %d-%d", from, to, min, max)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronLookup)
+	for t.Before(limit) {
+		switch {
+		case !c.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case !c.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
@@ -0,0 +1,132 @@
+package schedule
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxSkippedSends limits search of the send time outside quiet hours.
+const maxSkippedSends = 100000
+
+// QuietHours represents daily windows when pushes are not sent, weekends may have own window.
+// Window crossing midnight like 22:00-08:00 is taken by the day it ends, so Friday night
+// follows weekend rule and Sunday night follows weekdays rule.
+type QuietHours struct {
+	loc      *time.Location
+	weekdays *window
+	weekends *window
+}
+
+type window struct {
+	from time.Duration
+	to   time.Duration
+}
+
+// ParseQuietHours returns quiet hours of "HH:MM-HH:MM" windows, weekdays window is used on weekends
+// if weekends window is empty, "off" disables quiet hours.
+func ParseQuietHours(weekdays string, weekends string, loc *time.Location) (*QuietHours, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	q := &QuietHours{loc: loc}
+	var err error
+	q.weekdays, err = parseWindow(weekdays)
+	if err != nil {
+		return nil, err
+	}
+	q.weekends = q.weekdays
+	if weekends != "" {
+		q.weekends, err = parseWindow(weekends)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+func parseWindow(spec string) (*window, error) {
+	if spec == "" || spec == "off" {
+		return nil, nil
+	}
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("failed to parse window %q, expected HH:MM-HH:MM", spec)
+	}
+	from, err := parseClock(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseClock(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, errors.Errorf("window %q is empty", spec)
+	}
+
+	return &window{from: from, to: to}, nil
+}
+
+// Window returns quiet window containing t.
+func (q *QuietHours) Window(t time.Time) (start time.Time, end time.Time, ok bool) {
+	t = t.In(q.loc)
+	y, m, d := t.Date()
+	// Window containing t ends either today or tomorrow.
+	for i := 0; i <= 1; i++ {
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, q.loc)
+		w := q.weekdays
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			w = q.weekends
+		}
+		if w == nil {
+			continue
+		}
+		start, end = at(day, w.from), at(day, w.to)
+		if w.from > w.to {
+			start = at(day.AddDate(0, 0, -1), w.from)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return start, end, true
+		}
+	}
+
+	return time.Time{}, time.Time{}, false
+}
+
+// NearEdge reports whether t is outside quiet hours but within margin of the window.
+func (q *QuietHours) NearEdge(t time.Time, margin time.Duration) bool {
+	if _, _, ok := q.Window(t); ok {
+		return false
+	}
+	_, _, before := q.Window(t.Add(margin))
+	_, _, after := q.Window(t.Add(-margin))
+
+	return before || after
+}
+
+// Skip returns the first send of the schedule after t which is outside quiet hours.
+// Sends inside quiet hours are moved to the window end if deferred, otherwise they are dropped.
+func (q *QuietHours) Skip(s Schedule, t time.Time, deferred bool) time.Time {
+	next := s.Next(t)
+	for i := 0; i < maxSkippedSends && !next.IsZero(); i++ {
+		_, end, ok := q.Window(next)
+		if !ok {
+			return next
+		}
+		if deferred {
+			return end
+		}
+		next = s.Next(next)
+	}
+
+	return next
+}
+
+func at(day time.Time, offset time.Duration) time.Time {
+	h := offset / time.Hour
+	m := (offset % time.Hour) / time.Minute
+	return time.Date(day.Year(), day.Month(), day.Day(), int(h), int(m), 0, 0, day.Location())
+}
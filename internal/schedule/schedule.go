@@ -0,0 +1,238 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule returns send times.
+type Schedule interface {
+	// Next returns the first send time after t, zero time means there are no more sends.
+	Next(t time.Time) time.Time
+}
+
+// Every returns schedule sending every interval around the clock.
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	if e <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(e))
+}
+
+// Parse returns schedule of the spec, times are taken in the location. Supported specs are:
+//   - interval: "every 45m";
+//   - named: "[days] [HH:MM-HH:MM] every 45m" or "[days] HH:MM[,HH:MM...]",
+//     days are "daily", "weekdays", "weekends" or comma separated "mon", "tue-fri";
+//   - cron expression of five fields: "*/45 9-17 * * 1-5".
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 0 {
+		return nil, errors.New("schedule is empty")
+	}
+	if len(fields) == 5 && isCronField(fields[0]) {
+		return parseCron(fields, loc)
+	}
+
+	return parseNamed(fields, loc)
+}
+
+// LoadLocation returns location of the timezone name, local time is used if name is empty.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown timezone %q", name)
+	}
+
+	return loc, nil
+}
+
+func isCronField(field string) bool {
+	return strings.IndexFunc(field, func(r rune) bool {
+		return !strings.ContainsRune("0123456789*/,-", r)
+	}) < 0
+}
+
+// named is schedule sending on the days either at fixed times or every interval within the window.
+type named struct {
+	loc  *time.Location
+	days [7]bool
+	// times are offsets from the day start.
+	times []time.Duration
+	// window and interval are used if times are empty, window end is inclusive.
+	from     time.Duration
+	to       time.Duration
+	interval time.Duration
+}
+
+func parseNamed(fields []string, loc *time.Location) (Schedule, error) {
+	s := &named{loc: loc, to: 24 * time.Hour}
+	for i := range s.days {
+		s.days[i] = true
+	}
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") && fields[0] != "every" {
+		err := s.parseDays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.Contains(fields[0], "-") {
+		parts := strings.SplitN(fields[0], "-", 2)
+		from, err := parseClock(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseClock(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if to <= from {
+			return nil, errors.Errorf("window %q ends before it starts", fields[0])
+		}
+		s.from, s.to = from, to
+		fields = fields[1:]
+	} else if len(fields) > 0 && strings.Contains(fields[0], ":") {
+		for _, clock := range strings.Split(fields[0], ",") {
+			t, err := parseClock(clock)
+			if err != nil {
+				return nil, err
+			}
+			s.times = append(s.times, t)
+		}
+		if len(fields) > 1 {
+			return nil, errors.Errorf("unexpected %q after send times", strings.Join(fields[1:], " "))
+		}
+		return s, nil
+	}
+	if len(fields) != 2 || fields[0] != "every" {
+		return nil, errors.Errorf("expected \"every <interval>\", got %q", strings.Join(fields, " "))
+	}
+	interval, err := time.ParseDuration(fields[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse interval %q", fields[1])
+	}
+	if interval < time.Minute {
+		return nil, errors.Errorf("interval %s is less than a minute", interval)
+	}
+	s.interval = interval
+
+	return s, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func (s *named) parseDays(field string) error {
+	switch field {
+	case "daily":
+		return nil
+	case "weekdays":
+		field = "mon-fri"
+	case "weekends":
+		field = "sat,sun"
+	}
+	s.days = [7]bool{}
+	for _, part := range strings.Split(field, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		from, ok := weekdays[bounds[0]]
+		if !ok {
+			return errors.Errorf("unknown day %q", bounds[0])
+		}
+		to := from
+		if len(bounds) == 2 {
+			to, ok = weekdays[bounds[1]]
+			if !ok {
+				return errors.Errorf("unknown day %q", bounds[1])
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			s.days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	parts := strings.SplitN(clock, ":", 2)
+	if len(parts) != 2 {
+		return 0, errors.Errorf("failed to parse time %q, expected HH:MM", clock)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, errors.Errorf("failed to parse hours of %q", clock)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes != 0) {
+		return 0, errors.Errorf("failed to parse minutes of %q", clock)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func (s *named) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	// Schedule may match nothing for a week if days are empty.
+	for i := 0; i <= 7; i++ {
+		y, m, d := t.Date()
+		day := time.Date(y, m, d+i, 0, 0, 0, 0, s.loc)
+		if !s.days[day.Weekday()] {
+			continue
+		}
+		if next, ok := s.nextOfDay(day, t); ok {
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+// nextOfDay returns the first send time of the day after t.
+func (s *named) nextOfDay(day time.Time, t time.Time) (time.Time, bool) {
+	if len(s.times) > 0 {
+		var best time.Time
+		for _, offset := range s.times {
+			next := at(day, offset)
+			if next.After(t) && (best.IsZero() || next.Before(best)) {
+				best = next
+			}
+		}
+		return best, !best.IsZero()
+	}
+	start, end := at(day, s.from), at(day, s.to)
+	if t.Before(start) {
+		return start, true
+	}
+	steps := t.Sub(start)/s.interval + 1
+	next := start.Add(steps * s.interval)
+	if next.After(end) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	moscow, err := LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2020-05-08 is Friday.
+	at := func(day int, hour int, min int) time.Time {
+		return time.Date(2020, 5, day, hour, min, 0, 0, moscow)
+	}
+	cases := []struct {
+		spec     string
+		from     time.Time
+		expected []time.Time
+	}{
+		{"weekdays 9:00-18:00 every 45m", at(8, 8, 0), []time.Time{at(8, 9, 0), at(8, 9, 45), at(8, 10, 30)}},
+		{"weekdays 9:00-18:00 every 45m", at(8, 17, 10), []time.Time{at(8, 17, 15), at(8, 18, 0), at(11, 9, 0)}},
+		{"daily 08:00", at(8, 8, 0), []time.Time{at(9, 8, 0), at(10, 8, 0)}},
+		{"sat,sun 10:00,20:30", at(8, 12, 0), []time.Time{at(9, 10, 0), at(9, 20, 30), at(10, 10, 0), at(10, 20, 30), at(16, 10, 0)}},
+		{"every 2h", at(8, 23, 30), []time.Time{at(9, 0, 0), at(9, 2, 0)}},
+		{"*/45 9-17 * * 1-5", at(8, 17, 30), []time.Time{at(8, 17, 45), at(11, 9, 0), at(11, 9, 45)}},
+		{"0 8 1 * *", at(8, 12, 0), []time.Time{at(31, 0, 0).Add(32 * time.Hour)}},
+		// Stepped day of month is unrestricted for OR rule, so odd Mondays match only.
+		{"0 9 */2 * 1", at(8, 12, 0), []time.Time{at(11, 9, 0), at(25, 9, 0)}},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec, moscow)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		next := c.from
+		for _, expected := range c.expected {
+			next = s.Next(next)
+			if !next.Equal(expected) {
+				t.Fatalf("%q: expected %s, got %s", c.spec, expected, next)
+			}
+		}
+	}
+
+	for _, spec := range []string{"", "hourly", "daily 25:00", "18:00-9:00 every 1h", "mon-xyz 08:00", "every 10s", "61 * * * *"} {
+		_, err := Parse(spec, moscow)
+		if err == nil {
+			t.Errorf("%q: expected parse error", spec)
+		}
+	}
+}
+
+func TestNeverMatchingCron(t *testing.T) {
+	s, err := Parse("0 0 31 2 *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected no sends, got %s", next)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	// 2020-05-08 is Friday.
+	at := func(day int, hour int, min int) time.Time {
+		return time.Date(2020, 5, day, hour, min, 0, 0, time.UTC)
+	}
+	q, err := ParseQuietHours("22:00-08:00", "23:00-10:00", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		t     time.Time
+		quiet bool
+		end   time.Time
+	}{
+		{at(7, 23, 0), true, at(8, 8, 0)},
+		{at(8, 8, 0), false, time.Time{}},
+		// Friday night follows weekends window.
+		{at(8, 22, 30), false, time.Time{}},
+		{at(9, 9, 0), true, at(9, 10, 0)},
+		// Sunday night follows weekdays window.
+		{at(10, 22, 30), true, at(11, 8, 0)},
+	}
+	for _, c := range cases {
+		_, end, ok := q.Window(c.t)
+		if ok != c.quiet || !end.Equal(c.end) {
+			t.Errorf("%s: expected quiet %t until %s, got %t until %s", c.t, c.quiet, c.end, ok, end)
+		}
+	}
+	if !q.NearEdge(at(8, 8, 10), 30*time.Minute) || q.NearEdge(at(8, 12, 0), 30*time.Minute) {
+		t.Error("unexpected window edges")
+	}
+
+	hourly := Every(time.Hour)
+	if next := q.Skip(hourly, at(7, 21, 30), false); !next.Equal(at(8, 8, 30)) {
+		t.Errorf("expected dropped sends, next send at %s", next)
+	}
+	if next := q.Skip(hourly, at(7, 21, 30), true); !next.Equal(at(8, 8, 0)) {
+		t.Errorf("expected send deferred to window end, got %s", next)
+	}
+}
@@ -4,52 +4,170 @@ import (
 	"context"
 	"time"
 
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
 	"github.com/pachmu/skyeng-push-notificator/internal/state"
 	"github.com/sirupsen/logrus"
 )
 
+// edgeMargin is a time around quiet hours when cards are sent silently.
+const edgeMargin = 30 * time.Minute
+
+// maxDroppedSends limits search of the send time after do not disturb period.
+const maxDroppedSends = 100000
+
 // NewSender returns Sender struct.
-func NewSender(state *state.State) *Sender {
+func NewSender(state *state.State, notifier notifier.Notifier) *Sender {
 	return &Sender{
-		state: state,
+		state:    state,
+		notifier: notifier,
 	}
 }
 
 // Sender represents periodic sender logic.
 type Sender struct {
-	state *state.State
+	state    *state.State
+	notifier notifier.Notifier
+	// skip is a number of scheduled sends to drop.
+	skip int
 }
 
-// Run executes main application logic.
+// Run executes main application logic, cards are sent on start and then according to the schedule.
+// State commands are applied in the queue order.
 func (s *Sender) Run(ctx context.Context) error {
-	ticker := time.NewTicker(s.state.GetTimeInterval())
-	run := true
-	suspended := false
-	for run {
-		if !suspended {
-			err := s.state.WordsetCallback()
-			if err != nil {
-				logrus.Error(err)
+	timer := time.NewTimer(0)
+	stopTimer(timer)
+	defer timer.Stop()
+	// Commands queued before start are already reflected in the state.
+	s.state.TakeCommands()
+	if !s.state.GetStatus().Suspended {
+		s.sendLogged(ctx, false)
+		s.resetTimer(timer)
+	}
+	s.state.Applied()
+	for {
+		select {
+		case <-s.state.Commands():
+			for _, cmd := range s.state.TakeCommands() {
+				s.apply(ctx, timer, cmd)
+			}
+			s.state.Applied()
+		case <-timer.C:
+			if s.state.GetStatus().Suspended {
+				continue
 			}
+			if s.skip > 0 {
+				s.skip--
+				logrus.Info("Scheduled send is skipped")
+			} else {
+				s.sendLogged(ctx, false)
+			}
+			s.resetTimer(timer)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// apply executes the state command, cards are sent synchronously so commands are applied in order.
+// Schedule change, resume and reschedule are ignored if sending is suspended by the later command.
+func (s *Sender) apply(ctx context.Context, timer *time.Timer, cmd state.Command) {
+	logrus.Infof("Applying %s command", cmd)
+	switch cmd {
+	case state.CommandSetSchedule:
+		if s.state.GetStatus().Suspended {
+			return
+		}
+		s.sendLogged(ctx, false)
+		s.resetTimer(timer)
+	case state.CommandResume, state.CommandReschedule:
+		if s.state.GetStatus().Suspended {
+			return
+		}
+		s.resetTimer(timer)
+	case state.CommandSuspend:
+		stopTimer(timer)
+	case state.CommandTriggerNow:
+		s.sendLogged(ctx, true)
+	case state.CommandSkipNext:
+		s.skip++
+	}
+}
+
+func (s *Sender) sendLogged(ctx context.Context, forced bool) {
+	err := s.send(ctx, forced)
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// resetTimer sets timer to the next scheduled time, timer is stopped if schedule has no more sends.
+// Sends in quiet hours are dropped or deferred, sends in do not disturb period are dropped.
+func (s *Sender) resetTimer(timer *time.Timer) {
+	stopTimer(timer)
+	now := time.Now()
+	next := s.nextSend(now)
+	if next.IsZero() {
+		logrus.Warn("Schedule has no more sends")
+		return
+	}
+	logrus.Infof("Next send at %s", next.Format(time.RFC3339))
+	timer.Reset(next.Sub(now))
+}
+
+func (s *Sender) nextSend(now time.Time) time.Time {
+	sched := s.state.GetSchedule()
+	quiet, deferred := s.state.GetQuietHours()
+	dnd := s.state.GetDND()
+	next := func(t time.Time) time.Time {
+		if quiet == nil {
+			return sched.Next(t)
 		}
+		return quiet.Skip(sched, t, deferred)
+	}
+	send := next(now)
+	for i := 0; i < maxDroppedSends && !send.IsZero() && send.Before(dnd); i++ {
+		send = next(send)
+	}
+
+	return send
+}
+
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
 		select {
-		case timeInterval := <-s.state.GetChangeInterval():
-			ticker.Stop()
-			ticker = time.NewTicker(timeInterval)
-			suspended = false
-			continue
-		case <-ticker.C:
-			continue
-		case <-s.state.GetSuspendWork():
-			ticker.Stop()
-			suspended = true
-			continue
-		case <-ctx.Done():
-			ticker.Stop()
-			run = false
-			continue
+		case <-timer.C:
+		default:
 		}
+	}
+}
 
+// send delivers card of the current sending source, nothing is sent in quiet hours
+// or do not disturb period unless forced, cards near quiet hours are sent silently.
+func (s *Sender) send(ctx context.Context, forced bool) error {
+	now := time.Now()
+	if !forced && now.Before(s.state.GetDND()) {
+		logrus.Info("Do not disturb, send is dropped")
+		return nil
+	}
+	quiet, _ := s.state.GetQuietHours()
+	if quiet != nil && !forced {
+		if _, _, ok := quiet.Window(now); ok {
+			logrus.Info("Quiet hours, send is dropped")
+			return nil
+		}
 	}
-	return nil
+	card, err := s.state.WordsetCallback(ctx)
+	if err != nil {
+		return err
+	}
+	if card == nil {
+		return nil
+	}
+	if len(card.Meanings) == 0 {
+		logrus.Infof("Wordset %d has no words to send, send is dropped", card.WordsetID)
+		return nil
+	}
+	card.Silent = quiet != nil && quiet.NearEdge(now, edgeMargin)
+
+	return s.notifier.Notify(ctx, card)
 }
@@ -0,0 +1,193 @@
+package sender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
+	"github.com/pachmu/skyeng-push-notificator/internal/schedule"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng/skyengtest"
+	"github.com/pachmu/skyeng-push-notificator/internal/state"
+)
+
+const waitTimeout = 5 * time.Second
+
+type notifierFunc func(ctx context.Context, card *notifier.Card) error
+
+func (f notifierFunc) Notify(ctx context.Context, card *notifier.Card) error {
+	return f(ctx, card)
+}
+
+func TestSenderRun(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("daily", skyeng.Meaning{Text: "decision"})
+	client := srv.Client()
+
+	st := state.NewState(60)
+	st.SetWordsetCallback(func(ctx context.Context) (*notifier.Card, error) {
+		words, err := client.GetWords(ctx, ws)
+		if err != nil {
+			return nil, err
+		}
+		meanings, err := client.GetMeaning(ctx, words.Data...)
+		if err != nil {
+			return nil, err
+		}
+		return &notifier.Card{WordsetID: ws.ID, WordsetName: ws.Title, Meanings: meanings.Data}, nil
+	})
+	sent := make(chan int, 10)
+	ntf := notifierFunc(func(ctx context.Context, card *notifier.Card) error {
+		sent <- len(card.Meanings)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewSender(st, ntf).Run(ctx)
+	}()
+
+	expectSent := func() {
+		t.Helper()
+		select {
+		case n := <-sent:
+			if n != 1 {
+				t.Errorf("expected 1 word, got %d", n)
+			}
+		case <-time.After(waitTimeout):
+			t.Fatal("words are not sent")
+		}
+	}
+	expectSent()
+
+	status := st.SuspendWork()
+	if !status.Suspended {
+		t.Fatalf("expected suspended status, got %+v", status)
+	}
+	waitCtx, cancelWait := context.WithTimeout(ctx, waitTimeout)
+	defer cancelWait()
+	err := st.WaitApplied(waitCtx, status.ID)
+	if err != nil {
+		t.Fatal("suspend is not acknowledged by sender, got ", err)
+	}
+	st.TriggerNow()
+	expectSent()
+	if status := st.ChangeTimeInterval(60); status.Suspended {
+		t.Fatalf("expected schedule change to resume sending, got %+v", status)
+	}
+	expectSent()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("sender is not stopped")
+	}
+}
+
+func TestSenderSkipNext(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	st := state.NewState(60)
+	st.SetSchedule(schedule.Every(interval))
+	st.SetWordsetCallback(func(ctx context.Context) (*notifier.Card, error) {
+		return &notifier.Card{Meanings: []skyeng.Meaning{{Text: "decision"}}}, nil
+	})
+	sent := make(chan time.Time, 10)
+	ntf := notifierFunc(func(ctx context.Context, card *notifier.Card) error {
+		sent <- time.Now()
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = NewSender(st, ntf).Run(ctx)
+	}()
+
+	var start time.Time
+	select {
+	case start = <-sent:
+	case <-time.After(waitTimeout):
+		t.Fatal("words are not sent on start")
+	}
+	st.SkipNext()
+	select {
+	case next := <-sent:
+		if next.Sub(start) < 2*interval {
+			t.Errorf("expected the first scheduled send to be skipped, sent after %s", next.Sub(start))
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("words are not sent after skip")
+	}
+}
+
+func TestSenderDropsEmptyCard(t *testing.T) {
+	st := state.NewState(60)
+	st.SetWordsetCallback(func(ctx context.Context) (*notifier.Card, error) {
+		return &notifier.Card{WordsetID: 1}, nil
+	})
+	sent := make(chan int, 10)
+	ntf := notifierFunc(func(ctx context.Context, card *notifier.Card) error {
+		sent <- len(card.Meanings)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = NewSender(st, ntf).Run(ctx)
+	}()
+
+	st.TriggerNow()
+	st.SetWordsetCallback(func(ctx context.Context) (*notifier.Card, error) {
+		return &notifier.Card{WordsetID: 2, Meanings: []skyeng.Meaning{{Text: "decision"}}}, nil
+	})
+	st.TriggerNow()
+	select {
+	case n := <-sent:
+		if n != 1 {
+			t.Errorf("expected empty card to be dropped, got card of %d words", n)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("words are not sent")
+	}
+}
+
+func TestSenderDNDOff(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	st := state.NewState(60)
+	st.SetSchedule(schedule.Every(interval))
+	st.SetDND(time.Now().Add(time.Hour))
+	st.SetWordsetCallback(func(ctx context.Context) (*notifier.Card, error) {
+		return &notifier.Card{Meanings: []skyeng.Meaning{{Text: "decision"}}}, nil
+	})
+	sent := make(chan time.Time, 10)
+	ntf := notifierFunc(func(ctx context.Context, card *notifier.Card) error {
+		sent <- time.Now()
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = NewSender(st, ntf).Run(ctx)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("words are sent in do not disturb period")
+	case <-time.After(2 * interval):
+	}
+	off := time.Now()
+	st.SetDND(time.Time{})
+	select {
+	case next := <-sent:
+		if next.Sub(off) > 5*interval {
+			t.Errorf("expected send on the normal interval after do not disturb is off, sent after %s", next.Sub(off))
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("words are not sent after do not disturb is off")
+	}
+}
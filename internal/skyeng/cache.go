@@ -0,0 +1,363 @@
+package skyeng
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	errs "github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOptions configures caching client, zero values are taken from DefaultCacheOptions.
+type CacheOptions struct {
+	WordsetsTTL time.Duration
+	WordsTTL    time.Duration
+	MeaningsTTL time.Duration
+	// MaxEntries limits count of cached entries, least recently used entries are evicted first.
+	MaxEntries int
+}
+
+// DefaultCacheOptions returns default caching options.
+func DefaultCacheOptions() CacheOptions {
+	return CacheOptions{
+		WordsetsTTL: 10 * time.Minute,
+		WordsTTL:    10 * time.Minute,
+		MeaningsTTL: 24 * time.Hour,
+		MaxEntries:  10000,
+	}
+}
+
+// loadTimeout limits shared load of the value, the load is not canceled by callers.
+const loadTimeout = time.Minute
+
+// CacheStats represents cache usage statistics.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// CachingClient is Client caching responses of the wrapped client.
+type CachingClient struct {
+	client Client
+	opts   CacheOptions
+	cache  *lruCache
+	group  singleflight.Group
+	hits   uint64
+	misses uint64
+}
+
+// NewCachingClient returns Client caching wordsets, words and meanings of the given client.
+func NewCachingClient(c Client, opts CacheOptions) *CachingClient {
+	def := DefaultCacheOptions()
+	if opts.WordsetsTTL <= 0 {
+		opts.WordsetsTTL = def.WordsetsTTL
+	}
+	if opts.WordsTTL <= 0 {
+		opts.WordsTTL = def.WordsTTL
+	}
+	if opts.MeaningsTTL <= 0 {
+		opts.MeaningsTTL = def.MeaningsTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = def.MaxEntries
+	}
+
+	return &CachingClient{
+		client: c,
+		opts:   opts,
+		cache:  newLRUCache(opts.MaxEntries),
+	}
+}
+
+// Stats returns cache usage statistics.
+func (c *CachingClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: c.cache.len(),
+	}
+}
+
+func (c *CachingClient) GetWordsets(ctx context.Context, page int, pageSize int) (*WordsetsData, error) {
+	key := fmt.Sprintf("wordsets:%d:%d", page, pageSize)
+	val, err := c.get(ctx, key, c.opts.WordsetsTTL, func(ctx context.Context) (interface{}, error) {
+		return c.client.GetWordsets(ctx, page, pageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return val.(*WordsetsData), nil
+}
+
+func (c *CachingClient) GetWords(ctx context.Context, ws Wordset) (*WordsData, error) {
+	key := fmt.Sprintf("words:%d", ws.ID)
+	val, err := c.get(ctx, key, c.opts.WordsTTL, func(ctx context.Context) (interface{}, error) {
+		return c.client.GetWords(ctx, ws)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return val.(*WordsData), nil
+}
+
+// GetMeaning returns cached meanings requesting only missing ones from the wrapped client.
+func (c *CachingClient) GetMeaning(ctx context.Context, words ...Word) (*MeaningsData, error) {
+	cached := make(map[int]Meaning, len(words))
+	var missing []Word
+	for _, w := range words {
+		val, ok := c.cache.get(meaningKey(w.MeaningID))
+		if ok {
+			atomic.AddUint64(&c.hits, 1)
+			cached[w.MeaningID] = val.(Meaning)
+			continue
+		}
+		atomic.AddUint64(&c.misses, 1)
+		missing = append(missing, w)
+	}
+
+	if len(missing) > 0 {
+		ids := make([]string, 0, len(missing))
+		for _, w := range missing {
+			ids = append(ids, strconv.Itoa(w.MeaningID))
+		}
+		sort.Strings(ids)
+		val, err := c.do(ctx, "meanings:"+strings.Join(ids, ","), func(ctx context.Context) (interface{}, error) {
+			meanings, err := c.client.GetMeaning(ctx, missing...)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range meanings.Data {
+				c.cache.set(meaningKey(m.ID), m, c.opts.MeaningsTTL)
+			}
+			return meanings, nil
+		})
+		if err != nil && !errors.Is(err, ErrMeaningNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			for _, m := range val.(*MeaningsData).Data {
+				cached[m.ID] = m
+			}
+		}
+	}
+
+	var meanings MeaningsData
+	seen := make(map[int]bool, len(words))
+	for _, w := range words {
+		if seen[w.MeaningID] {
+			continue
+		}
+		seen[w.MeaningID] = true
+		if m, ok := cached[w.MeaningID]; ok {
+			meanings.Data = append(meanings.Data, m)
+			continue
+		}
+		meanings.Missing = append(meanings.Missing, w.MeaningID)
+	}
+	if len(meanings.Data) == 0 {
+		return nil, errs.Wrapf(ErrMeaningNotFound, "meaningID: %v", meanings.Missing)
+	}
+
+	return &meanings, nil
+}
+
+// Search caches dictionary search results as long as meanings.
+func (c *CachingClient) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	key := "search:" + strings.ToLower(strings.TrimSpace(query))
+	val, err := c.get(ctx, key, c.opts.MeaningsTTL, func(ctx context.Context) (interface{}, error) {
+		return c.client.Search(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return val.([]SearchResult), nil
+}
+
+// CreateWordset creates wordset and drops cached wordsets pages.
+func (c *CachingClient) CreateWordset(ctx context.Context, title string) (*Wordset, error) {
+	wordset, err := c.client.CreateWordset(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.deletePrefix("wordsets:")
+
+	return wordset, nil
+}
+
+// AddMeaning adds meaning to the wordset and drops cached words of the wordset.
+func (c *CachingClient) AddMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	err := c.client.AddMeaning(ctx, wordsetID, meaningID)
+	c.cache.delete(fmt.Sprintf("words:%d", wordsetID))
+
+	return err
+}
+
+// RemoveMeaning removes meaning from the wordset and drops cached words of the wordset.
+func (c *CachingClient) RemoveMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	err := c.client.RemoveMeaning(ctx, wordsetID, meaningID)
+	c.cache.delete(fmt.Sprintf("words:%d", wordsetID))
+
+	return err
+}
+
+// GetProgress is not cached because progress changes with every training.
+func (c *CachingClient) GetProgress(ctx context.Context, words ...Word) ([]WordProgress, error) {
+	return c.client.GetProgress(ctx, words...)
+}
+
+// get returns cached value or loads and caches it, concurrent loads of the same key are deduplicated.
+func (c *CachingClient) get(ctx context.Context, key string, ttl time.Duration, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if val, ok := c.cache.get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return val, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	return c.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		val, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.set(key, val, ttl)
+		return val, nil
+	})
+}
+
+// do runs load once for concurrent callers of the same key. The load context keeps values of the first
+// caller but not its cancellation, so the caller leaving early does not fail other callers.
+func (c *CachingClient) do(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(detached{ctx}, loadTimeout)
+		defer cancel()
+		return load(loadCtx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, errs.WithStack(ctx.Err())
+	}
+}
+
+// detached is context keeping values of the parent context without its deadline and cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+func meaningKey(meaningID int) string {
+	return fmt.Sprintf("meaning:%d", meaningID)
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// lruCache is size bounded cache with expiring entries.
+type lruCache struct {
+	maxEntries int
+	items      map[string]*list.Element
+	order      *list.List
+	mx         sync.Mutex
+}
+
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+
+	return entry.value, true
+}
+
+func (c *lruCache) set(key string, value interface{}, ttl time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) delete(key string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+func (c *lruCache) deletePrefix(prefix string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+func (c *lruCache) len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,189 @@
+package skyeng_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+)
+
+// fakeClient is Client counting calls, calls wait for release if it is set.
+type fakeClient struct {
+	skyeng.Client
+	calls   int32
+	release chan struct{}
+}
+
+func (c *fakeClient) wait(ctx context.Context) error {
+	atomic.AddInt32(&c.calls, 1)
+	if c.release == nil {
+		return nil
+	}
+	select {
+	case <-c.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *fakeClient) GetWords(ctx context.Context, ws skyeng.Wordset) (*skyeng.WordsData, error) {
+	err := c.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &skyeng.WordsData{Data: []skyeng.Word{{ID: ws.ID, MeaningID: ws.ID}}}, nil
+}
+
+func (c *fakeClient) GetMeaning(ctx context.Context, words ...skyeng.Word) (*skyeng.MeaningsData, error) {
+	err := c.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var meanings skyeng.MeaningsData
+	for _, w := range words {
+		meanings.Data = append(meanings.Data, skyeng.Meaning{ID: w.MeaningID})
+	}
+	return &meanings, nil
+}
+
+func (c *fakeClient) callCount() int {
+	return int(atomic.LoadInt32(&c.calls))
+}
+
+func TestCacheTTL(t *testing.T) {
+	fake := &fakeClient{}
+	client := skyeng.NewCachingClient(fake, skyeng.CacheOptions{WordsTTL: 50 * time.Millisecond})
+	ws := skyeng.Wordset{ID: 1}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetWords(context.Background(), ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.callCount() != 1 {
+		t.Fatalf("expected cached words, got %d calls", fake.callCount())
+	}
+	stats := client.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	_, err := client.GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.callCount() != 2 {
+		t.Errorf("expected expired words to be loaded again, got %d calls", fake.callCount())
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	fake := &fakeClient{}
+	client := skyeng.NewCachingClient(fake, skyeng.CacheOptions{MaxEntries: 2})
+	get := func(id int) {
+		t.Helper()
+		_, err := client.GetWords(context.Background(), skyeng.Wordset{ID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get(1)
+	get(2)
+	// Wordset 1 becomes the most recently used, so wordset 2 is evicted.
+	get(1)
+	get(3)
+	if stats := client.Stats(); stats.Entries != 2 {
+		t.Fatalf("expected 2 entries, got %+v", stats)
+	}
+	calls := fake.callCount()
+	get(1)
+	if fake.callCount() != calls {
+		t.Error("recently used entry is evicted")
+	}
+	get(2)
+	if fake.callCount() != calls+1 {
+		t.Error("least recently used entry is not evicted")
+	}
+}
+
+func TestCacheMeaningsPartialHit(t *testing.T) {
+	fake := &fakeClient{}
+	client := skyeng.NewCachingClient(fake, skyeng.CacheOptions{})
+
+	_, err := client.GetMeaning(context.Background(), skyeng.Word{MeaningID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meanings, err := client.GetMeaning(context.Background(), skyeng.Word{MeaningID: 1}, skyeng.Word{MeaningID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meanings.Data) != 2 || meanings.Data[0].ID != 1 || meanings.Data[1].ID != 2 {
+		t.Fatalf("unexpected meanings %+v", meanings.Data)
+	}
+	if stats := client.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheMergesConcurrentLoads(t *testing.T) {
+	fake := &fakeClient{release: make(chan struct{})}
+	client := skyeng.NewCachingClient(fake, skyeng.CacheOptions{})
+	ws := skyeng.Wordset{ID: 1}
+
+	// The first caller leaves early, the load must go on for the rest.
+	ctx, cancel := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := client.GetWords(ctx, ws)
+		firstDone <- err
+	}()
+	waitCalls(t, fake, 1)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetWords(context.Background(), ws)
+			errs <- err
+		}()
+	}
+	cancel()
+	if err := <-firstDone; err == nil {
+		t.Error("expected canceled caller to fail")
+	}
+	// Give waiting callers time to join the load before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(fake.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected shared load to succeed, got %v", err)
+		}
+	}
+	if fake.callCount() != 1 {
+		t.Errorf("expected single load, got %d calls", fake.callCount())
+	}
+}
+
+func waitCalls(t *testing.T, fake *fakeClient, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.callCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d calls, got %d", n, fake.callCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -2,6 +2,7 @@ package skyeng
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,13 +12,19 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	errs "github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	// maxHttpRetries represents max count of retries.
 	maxHttpRetries = 3
+	// defaultTimeout is a deadline for a single client call.
+	defaultTimeout = 30 * time.Second
 )
 
 type Wordset struct {
@@ -27,6 +34,12 @@ type Wordset struct {
 
 type WordsetsData struct {
 	Data []Wordset `json:"data"`
+	Meta Meta      `json:"meta"`
+}
+
+// IsLastPage reports whether there are no more wordsets after this page.
+func (d *WordsetsData) IsLastPage() bool {
+	return isLastPage(d.Meta, d.Meta.CurrentPage, len(d.Data))
 }
 
 type Word struct {
@@ -34,12 +47,35 @@ type Word struct {
 	MeaningID int `json:"meaningId"`
 }
 
+// Meta represents pagination metadata of list responses.
+type Meta struct {
+	CurrentPage int `json:"currentPage"`
+	LastPage    int `json:"lastPage"`
+	PageSize    int `json:"pageSize"`
+	Total       int `json:"total"`
+}
+
+// WordProgress represents user training progress of the word.
+type WordProgress struct {
+	MeaningID int  `json:"meaningId"`
+	IsLearned bool `json:"isLearned"`
+	// Progress is a training progress in percents.
+	Progress      int        `json:"progress"`
+	LastTrainedAt *time.Time `json:"lastTrainedAt"`
+}
+
+type ProgressData struct {
+	Data []WordProgress `json:"data"`
+}
+
 type WordsData struct {
 	Data []Word `json:"data"`
+	Meta Meta   `json:"meta"`
 }
 
 type Translation struct {
 	Text string `json:"text"`
+	Note string `json:"note"`
 }
 
 type Transcription struct {
@@ -47,21 +83,74 @@ type Transcription struct {
 }
 
 type Example struct {
-	Text string `json:"text"`
+	Text     string `json:"text"`
+	SoundURL string `json:"soundUrl"`
 }
 
 type Definition struct {
-	Text string `json:"text"`
+	Text     string `json:"text"`
+	SoundURL string `json:"soundUrl"`
+}
+
+type Image struct {
+	URL string `json:"url"`
+}
+
+// AlternativeTranslation represents other meaning of the same word.
+type AlternativeTranslation struct {
+	Text        string      `json:"text"`
+	Translation Translation `json:"translation"`
+}
+
+// SimilarMeaning represents link to the meaning with similar translation.
+type SimilarMeaning struct {
+	MeaningID                int         `json:"meaningId"`
+	FrequencyPercent         json.Number `json:"frequencyPercent"`
+	PartOfSpeechAbbreviation string      `json:"partOfSpeechAbbreviation"`
+	Translation              Translation `json:"translation"`
 }
 
 type Meaning struct {
-	ID            int         `json:"id"`
-	MeaningID     int         `json:"meaningId"`
-	Text          string      `json:"text"`
-	Translation   Translation `json:"translation"`
-	Definition    Definition  `json:"definition"`
-	Examples      []Example   `json:"examples"`
-	Transcription string      `json:"transcription"`
+	ID                             int                      `json:"id"`
+	MeaningID                      int                      `json:"meaningId"`
+	WordID                         int                      `json:"wordId"`
+	DifficultyLevel                int                      `json:"difficultyLevel"`
+	PartOfSpeechCode               string                   `json:"partOfSpeechCode"`
+	Prefix                         string                   `json:"prefix"`
+	Text                           string                   `json:"text"`
+	SoundURL                       string                   `json:"soundUrl"`
+	Transcription                  string                   `json:"transcription"`
+	Translation                    Translation              `json:"translation"`
+	Definition                     Definition               `json:"definition"`
+	Examples                       []Example                `json:"examples"`
+	Images                         []Image                  `json:"images"`
+	AlternativeTranslations        []AlternativeTranslation `json:"alternativeTranslations"`
+	MeaningsWithSimilarTranslation []SimilarMeaning         `json:"meaningsWithSimilarTranslation"`
+}
+
+// SearchResult represents dictionary word found by search.
+type SearchResult struct {
+	ID       int             `json:"id"`
+	Text     string          `json:"text"`
+	Meanings []SearchMeaning `json:"meanings"`
+}
+
+// SearchMeaning represents short meaning of the found word, full meaning may be requested by ID.
+type SearchMeaning struct {
+	ID               int         `json:"id"`
+	PartOfSpeechCode string      `json:"partOfSpeechCode"`
+	Translation      Translation `json:"translation"`
+	PreviewURL       string      `json:"previewUrl"`
+	ImageURL         string      `json:"imageUrl"`
+	Transcription    string      `json:"transcription"`
+	SoundURL         string      `json:"soundUrl"`
+}
+
+// MeaningsData represents meanings of the requested words.
+type MeaningsData struct {
+	Data []Meaning
+	// Missing contains requested meaning IDs absent in the response.
+	Missing []int
 }
 
 var ErrUnauthorized = errors.New("unauthorized")
@@ -69,20 +158,60 @@ var ErrWordsetNotFound = errors.New("wordset not found")
 var ErrMeaningNotFound = errors.New("meaning not found")
 
 type Client interface {
-	GetWordsets(page int) ([]Wordset, error)
-	GetWords(ws Wordset) ([]Word, error)
-	GetMeaning(w ...Word) ([]Meaning, error)
+	GetWordsets(ctx context.Context, page int, pageSize int) (*WordsetsData, error)
+	GetWords(ctx context.Context, ws Wordset) (*WordsData, error)
+	GetMeaning(ctx context.Context, w ...Word) (*MeaningsData, error)
+	Search(ctx context.Context, query string) ([]SearchResult, error)
+	CreateWordset(ctx context.Context, title string) (*Wordset, error)
+	AddMeaning(ctx context.Context, wordsetID int, meaningID int) error
+	RemoveMeaning(ctx context.Context, wordsetID int, meaningID int) error
+	GetProgress(ctx context.Context, w ...Word) ([]WordProgress, error)
+}
+
+// Option configures client.
+type Option func(c *client)
+
+// WithTimeout sets deadline for every client call, including authorization and all pages requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *client) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// WithEndpoints overrides skyeng api endpoints, empty values keep defaults.
+func WithEndpoints(authEndpoint string, wordsEndpoint string, dictEndpoint string) Option {
+	return func(c *client) {
+		if authEndpoint != "" {
+			c.authEndpoint = authEndpoint
+		}
+		if wordsEndpoint != "" {
+			c.wordsEndpoint = wordsEndpoint
+		}
+		if dictEndpoint != "" {
+			c.dictEndpoint = dictEndpoint
+		}
+	}
 }
 
-func NewClient(username string, password string) Client {
-	return &client{
+func NewClient(username string, password string, opts ...Option) Client {
+	c := &client{
 		authEndpoint:  "https://id.skyeng.ru",
 		wordsEndpoint: "https://api.words.skyeng.ru/api",
 		dictEndpoint:  "https://dictionary.skyeng.ru/api",
 		username:      username,
 		password:      password,
 		client:        &http.Client{},
+		timeout:       defaultTimeout,
+		retry:         DefaultRetryPolicy(),
+		tokenStore:    noopTokenStore{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 type client struct {
@@ -92,21 +221,40 @@ type client struct {
 	username      string
 	password      string
 	client        *http.Client
-	token         string
+	timeout       time.Duration
+	retry         RetryPolicy
+	tokenStore    TokenStore
+	session       *Session
+	sessionOnce   sync.Once
+	sessionMx     sync.Mutex
+	authGroup     singleflight.Group
 }
 
 const resultMaxPageSize = 100
 
-func (c *client) GetWordsets(page int) ([]Wordset, error) {
+// searchPageSize limits count of dictionary search candidates.
+const searchPageSize = 10
+
+const (
+	// meaningsChunkSize limits count of meaning IDs in a single request to keep URL short.
+	meaningsChunkSize = 50
+	// meaningsConcurrency limits count of concurrent meanings requests.
+	meaningsConcurrency = 4
+)
+
+// GetWordsets returns one page of user wordsets. Pages are numbered from 1.
+func (c *client) GetWordsets(ctx context.Context, page int, pageSize int) (*WordsetsData, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 	var wordsetsData WordsetsData
-	var pageSuff string
-	if page == 0 {
-		pageSuff = fmt.Sprintf("?pageSize=%d&page=1", resultMaxPageSize)
-	} else {
-		pageSuff = fmt.Sprintf("?page=%d", page)
-	}
-	wordsetsURL := fmt.Sprintf("%s/for-vimbox/v1/wordsets.json%s", c.wordsEndpoint, pageSuff)
-	err := c.invoke("GET", wordsetsURL, nil, func(resp []byte) error {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > resultMaxPageSize {
+		pageSize = resultMaxPageSize
+	}
+	wordsetsURL := fmt.Sprintf("%s/for-vimbox/v1/wordsets.json?pageSize=%d&page=%d", c.wordsEndpoint, pageSize, page)
+	err := c.invoke(ctx, "GET", wordsetsURL, nil, func(resp []byte) error {
 		err := json.Unmarshal(resp, &wordsetsData)
 		if err != nil {
 			return errs.WithStack(err)
@@ -117,15 +265,44 @@ func (c *client) GetWordsets(page int) ([]Wordset, error) {
 	if err != nil {
 		return nil, err
 	}
+	if wordsetsData.Meta.PageSize == 0 {
+		wordsetsData.Meta.PageSize = pageSize
+	}
+	if wordsetsData.Meta.CurrentPage == 0 {
+		wordsetsData.Meta.CurrentPage = page
+	}
 
-	return wordsetsData.Data, nil
+	return &wordsetsData, nil
 }
 
-func (c *client) GetWords(ws Wordset) ([]Word, error) {
+// GetWords returns all words of the wordset, walking through every page.
+func (c *client) GetWords(ctx context.Context, ws Wordset) (*WordsData, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
 	var words WordsData
-	wordsURL := fmt.Sprintf(c.wordsEndpoint+"/v1/wordsets/%d/words.json?pageSize=100&page=1", ws.ID)
+	for page := 1; ; page++ {
+		wordsPage, err := c.getWordsPage(ctx, ws, page)
+		if err != nil {
+			return nil, err
+		}
+		words.Data = append(words.Data, wordsPage.Data...)
+		words.Meta = wordsPage.Meta
+		if isLastPage(wordsPage.Meta, page, len(wordsPage.Data)) {
+			break
+		}
+	}
+	if words.Meta.Total == 0 {
+		words.Meta.Total = len(words.Data)
+	}
 
-	err := c.invoke("GET", wordsURL, nil, func(resp []byte) error {
+	return &words, nil
+}
+
+func (c *client) getWordsPage(ctx context.Context, ws Wordset, page int) (*WordsData, error) {
+	var words WordsData
+	wordsURL := fmt.Sprintf(c.wordsEndpoint+"/v1/wordsets/%d/words.json?pageSize=%d&page=%d", ws.ID, resultMaxPageSize, page)
+
+	err := c.invoke(ctx, "GET", wordsURL, nil, func(resp []byte) error {
 		err := json.Unmarshal(resp, &words)
 		if err != nil {
 			return errs.WithStack(err)
@@ -133,23 +310,152 @@ func (c *client) GetWords(ws Wordset) ([]Word, error) {
 
 		return nil
 	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, errs.Wrapf(ErrWordsetNotFound, "wordsetID: %d", ws.ID)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return words.Data, nil
+	return &words, nil
 }
 
-func (c *client) GetMeaning(words ...Word) ([]Meaning, error) {
-	var meanings []Meaning
-	var m []string
+// isLastPage reports whether there is nothing to fetch after the given page.
+func isLastPage(meta Meta, page int, pageLen int) bool {
+	if pageLen == 0 {
+		return true
+	}
+	if meta.LastPage != 0 {
+		return page >= meta.LastPage
+	}
+	pageSize := meta.PageSize
+	if pageSize == 0 {
+		pageSize = resultMaxPageSize
+	}
+
+	return pageLen < pageSize
+}
+
+// GetMeaning returns meanings of the words in order of the words. Large lists are requested
+// by chunks concurrently, meanings absent in the responses are reported in MeaningsData.Missing.
+func (c *client) GetMeaning(ctx context.Context, words ...Word) (*MeaningsData, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	ids := uniqueMeaningIDs(words)
+	chunks := chunkIDs(ids, meaningsChunkSize)
+
+	results := make([][]Meaning, len(chunks))
+	sem := make(chan struct{}, meaningsConcurrency)
+	errGr, gctx := errgroup.WithContext(ctx)
+	for i, chunk := range chunks {
+		i, chunk := i, chunk
+		errGr.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-gctx.Done():
+				return errs.WithStack(gctx.Err())
+			}
+			defer func() { <-sem }()
+			meanings, err := c.getMeaningsChunk(gctx, chunk)
+			if err != nil {
+				return err
+			}
+			results[i] = meanings
+			return nil
+		})
+	}
+	err := errGr.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]Meaning, len(ids))
+	for _, meanings := range results {
+		for _, m := range meanings {
+			byID[m.ID] = m
+		}
+	}
+	var meanings MeaningsData
+	for _, id := range ids {
+		m, ok := byID[id]
+		if !ok {
+			meanings.Missing = append(meanings.Missing, id)
+			continue
+		}
+		meanings.Data = append(meanings.Data, m)
+	}
+	if len(meanings.Data) == 0 {
+		return nil, errs.Wrapf(ErrMeaningNotFound, "meaningID: %v", ids)
+	}
+	return &meanings, nil
+}
+
+// GetProgress returns user training progress of the words.
+func (c *client) GetProgress(ctx context.Context, words ...Word) ([]WordProgress, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var progress []WordProgress
+	for _, chunk := range chunkIDs(uniqueMeaningIDs(words), meaningsChunkSize) {
+		m := make([]string, 0, len(chunk))
+		for _, id := range chunk {
+			m = append(m, strconv.Itoa(id))
+		}
+		progressURL := fmt.Sprintf("%s/v1/words/progress.json?meaningIds=%s", c.wordsEndpoint, strings.Join(m, ","))
+		var progressData ProgressData
+		err := c.invoke(ctx, "GET", progressURL, nil, func(resp []byte) error {
+			err := json.Unmarshal(resp, &progressData)
+			if err != nil {
+				return errs.WithStack(err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, progressData.Data...)
+	}
+
+	return progress, nil
+}
+
+func uniqueMeaningIDs(words []Word) []int {
+	var ids []int
+	seen := make(map[int]bool, len(words))
 	for _, word := range words {
-		m = append(m, strconv.Itoa(word.MeaningID))
+		if seen[word.MeaningID] {
+			continue
+		}
+		seen[word.MeaningID] = true
+		ids = append(ids, word.MeaningID)
+	}
+
+	return ids
+}
+
+func chunkIDs(ids []int, size int) [][]int {
+	var chunks [][]int
+	for start := 0; start < len(ids); start += size {
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunks = append(chunks, ids[start:end])
+	}
+
+	return chunks
+}
+
+func (c *client) getMeaningsChunk(ctx context.Context, ids []int) ([]Meaning, error) {
+	var meanings []Meaning
+	m := make([]string, 0, len(ids))
+	for _, id := range ids {
+		m = append(m, strconv.Itoa(id))
 	}
 	meaningIDs := strings.Join(m, ",")
 	wordsURL := fmt.Sprintf(c.dictEndpoint+"/for-services/v2/meanings?ids=%s", meaningIDs)
 
-	err := c.invoke("GET", wordsURL, nil, func(resp []byte) error {
+	err := c.invoke(ctx, "GET", wordsURL, nil, func(resp []byte) error {
 		err := json.Unmarshal(resp, &meanings)
 		if err != nil {
 			return errs.WithStack(err)
@@ -160,120 +466,245 @@ func (c *client) GetMeaning(words ...Word) ([]Meaning, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(meanings) == 0 {
-		return nil, errs.Wrapf(ErrMeaningNotFound, "meaningID: %s", meaningIDs)
-	}
+
 	return meanings, nil
 }
 
-func (c *client) invoke(method string, URL string, body []byte, f func(resp []byte) error) error {
-	for i := 0; i < maxHttpRetries; i++ {
-		var respBody []byte
-		err := func() error {
-			req, err := http.NewRequest(method, URL, bytes.NewBuffer(body))
-			if err != nil {
-				return errs.WithStack(err)
-			}
-			req.Header = http.Header{
-				"authorization": []string{"Bearer " + c.token},
-			}
+// Search looks up words in skyeng dictionary and returns candidates with their meanings.
+func (c *client) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var results []SearchResult
+	searchURL := fmt.Sprintf(
+		"%s/public/v1/words/search?search=%s&page=1&pageSize=%d",
+		c.dictEndpoint, url.QueryEscape(query), searchPageSize,
+	)
+	err := c.invoke(ctx, "GET", searchURL, nil, func(resp []byte) error {
+		err := json.Unmarshal(resp, &results)
+		if err != nil {
+			return errs.WithStack(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			resp, err := c.client.Do(req)
+	return results, nil
+}
 
-			if err != nil {
-				return errs.WithStack(err)
-			}
-			if resp.StatusCode == http.StatusUnauthorized {
-				return errs.WithStack(ErrUnauthorized)
-			}
+// CreateWordset creates new user wordset.
+func (c *client) CreateWordset(ctx context.Context, title string) (*Wordset, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	body, err := json.Marshal(map[string]string{"title": title})
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	var wordset struct {
+		Data Wordset `json:"data"`
+	}
+	wordsetsURL := fmt.Sprintf("%s/v1/wordsets.json", c.wordsEndpoint)
+	err = c.invoke(ctx, "POST", wordsetsURL, body, func(resp []byte) error {
+		err := json.Unmarshal(resp, &wordset)
+		if err != nil {
+			return errs.WithStack(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &wordset.Data, nil
+}
+
+// AddMeaning adds meaning to the user wordset.
+func (c *client) AddMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	body, err := json.Marshal(map[string]int{"meaningId": meaningID})
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	wordsURL := fmt.Sprintf("%s/v1/wordsets/%d/words.json", c.wordsEndpoint, wordsetID)
+	err = c.invoke(ctx, "POST", wordsURL, body, func(resp []byte) error {
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return errs.Wrapf(ErrWordsetNotFound, "wordsetID: %d", wordsetID)
+	}
+
+	return err
+}
+
+// RemoveMeaning removes meaning from the user wordset.
+func (c *client) RemoveMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	wordURL := fmt.Sprintf("%s/v1/wordsets/%d/words/%d.json", c.wordsEndpoint, wordsetID, meaningID)
+	err := c.invoke(ctx, "DELETE", wordURL, nil, func(resp []byte) error {
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return errs.Wrapf(ErrMeaningNotFound, "wordsetID: %d, meaningID: %d", wordsetID, meaningID)
+	}
 
-			defer resp.Body.Close()
-			respBody, err = ioutil.ReadAll(resp.Body)
+	return err
+}
+
+// invoke sends request retrying rejected tokens, GET requests are also retried on temporary failures.
+// Other requests are not retried since a lost response of the applied write would duplicate it.
+func (c *client) invoke(ctx context.Context, method string, URL string, body []byte, f func(resp []byte) error) error {
+	var lastErr error
+	for i := 0; i < c.retry.MaxAttempts; i++ {
+		if i > 0 && !errors.Is(lastErr, ErrUnauthorized) {
+			err := sleep(ctx, c.retry.backoff(i, lastErr))
 			if err != nil {
-				return errs.WithStack(err)
+				return err
 			}
-
-			return nil
-		}()
+		}
+		token, err := c.accessToken(ctx)
 		if err != nil {
-			if errors.Is(err, ErrUnauthorized) {
-				c.token, err = c.auth()
-				if err != nil {
-					return err
-				}
-				continue
-			}
-
 			return err
 		}
-		err = f(respBody)
-		if err != nil {
+		respBody, err := c.do(ctx, method, URL, body, token)
+		if err == nil {
+			return f(respBody)
+		}
+		lastErr = err
+		if errors.Is(err, ErrUnauthorized) {
+			c.invalidateToken(token)
+			continue
+		}
+		if method != http.MethodGet || !isRetryable(err) {
 			return err
 		}
-		break
 	}
 
-	return nil
+	return lastErr
 }
 
-func (c *client) auth() (string, error) {
-	resp, err := http.Get(c.authEndpoint + "/en/frame/login")
+func (c *client) do(ctx context.Context, method string, URL string, body []byte, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, URL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	req.Header = http.Header{
+		"authorization": []string{"Bearer " + token},
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", errs.WithStack(err)
+		if ctx.Err() != nil {
+			return nil, errs.WithStack(ctx.Err())
+		}
+		return nil, errs.WithStack(fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, errs.WithStack(ErrUnauthorized)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errs.WithStack(fmt.Errorf("%w: %v", ErrUpstreamUnavailable, err))
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, errs.WithStack(newStatusError(resp, respBody))
+	}
+
+	return respBody, nil
+}
+
+// auth logs in with user credentials and returns new session.
+func (c *client) auth(ctx context.Context) (*Session, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.authEndpoint+"/en/frame/login", nil)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errs.WithStack(err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
 
 	cmpl, err := regexp.Compile("name=\"csrfToken\" value=\"([^\"]+)\"")
 	if err != nil {
-		return "", errs.WithStack(err)
+		return nil, errs.WithStack(err)
 	}
 	submch := cmpl.FindSubmatch(body)
 	if len(submch) != 2 {
-		return "", errs.WithStack(errors.New("failed to get csrfToken, failed to parse html"))
+		return nil, errs.WithStack(errors.New("failed to get csrfToken, failed to parse html"))
 	}
 	csrfToken := string(submch[1])
 
 	sessionGlbl, err := getSessionGlobal(resp.Cookies())
+	if err != nil {
+		return nil, err
+	}
 
 	loginUrl := c.authEndpoint + "/en/frame/login-submit"
-	req, err := http.NewRequest("POST", loginUrl,
+	req, err = http.NewRequestWithContext(ctx, "POST", loginUrl,
 		strings.NewReader(url.Values{"csrfToken": {csrfToken}, "username": {c.username}, "password": {c.password}}.Encode()))
 	if err != nil {
-		return "", errs.WithStack(err)
+		return nil, errs.WithStack(err)
 	}
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.AddCookie(&http.Cookie{
 		Name:  "session_global",
 		Value: sessionGlbl,
 	})
-	resp, err = c.client.Do(req)
-
+	loginResp, err := c.client.Do(req)
 	if err != nil {
-		return "", errs.WithStack(err)
+		return nil, errs.WithStack(err)
+	}
+	loginResp.Body.Close()
+	sessionGlbl, err = getSessionGlobal(loginResp.Cookies())
+	if err != nil {
+		return nil, err
 	}
-	sessionGlbl, err = getSessionGlobal(resp.Cookies())
 
+	return c.refreshToken(ctx, sessionGlbl)
+}
+
+// refreshToken issues new jwt for the logged in session.
+func (c *client) refreshToken(ctx context.Context, sessionGlbl string) (*Session, error) {
 	jwtUrl := c.authEndpoint + "/user-api/v1/auth/jwt"
-	req, err = http.NewRequest("POST", jwtUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", jwtUrl, nil)
 	if err != nil {
-		return "", errs.WithStack(err)
+		return nil, errs.WithStack(err)
 	}
 	req.AddCookie(&http.Cookie{
 		Name:  "session_global",
 		Value: sessionGlbl,
 	})
-	resp, err = c.client.Do(req)
-
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", errs.WithStack(err)
+		return nil, errs.WithStack(err)
 	}
+	resp.Body.Close()
 	token, err := getAccessToken(resp.Cookies())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return token, nil
+	return &Session{
+		Token:     token,
+		ExpiresAt: tokenExpiry(token),
+		Cookies: map[string]string{
+			"session_global": sessionGlbl,
+		},
+	}, nil
 }
 
 func getSessionGlobal(cookies []*http.Cookie) (string, error) {
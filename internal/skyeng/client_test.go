@@ -0,0 +1,312 @@
+package skyeng_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng/skyengtest"
+)
+
+var fastRetries = skyeng.WithRetryPolicy(skyeng.RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    time.Millisecond,
+})
+
+func meanings(count int) []skyeng.Meaning {
+	var res []skyeng.Meaning
+	for i := 0; i < count; i++ {
+		res = append(res, skyeng.Meaning{
+			Text:        fmt.Sprintf("word%d", i),
+			Translation: skyeng.Translation{Text: fmt.Sprintf("слово%d", i)},
+		})
+	}
+
+	return res
+}
+
+func TestClientGetWordsAllPages(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("big", meanings(250)...)
+
+	words, err := srv.Client().GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words.Data) != 250 || words.Meta.Total != 250 {
+		t.Fatalf("expected 250 words, got %d, total %d", len(words.Data), words.Meta.Total)
+	}
+	if srv.Logins() != 1 {
+		t.Errorf("expected single login, got %d", srv.Logins())
+	}
+}
+
+func TestClientGetWordsUnknownWordset(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+
+	_, err := srv.Client().GetWords(context.Background(), skyeng.Wordset{ID: 100500})
+	if !errors.Is(err, skyeng.ErrWordsetNotFound) {
+		t.Fatalf("expected ErrWordsetNotFound, got %v", err)
+	}
+}
+
+func TestAllWordsets(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	for i := 0; i < 130; i++ {
+		srv.AddWordset(fmt.Sprintf("wordset %d", i))
+	}
+
+	it := skyeng.AllWordsets(context.Background(), srv.Client())
+	wordsets, err := it.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wordsets) != 130 || it.Total() != 130 {
+		t.Fatalf("expected 130 wordsets, got %d, total %d", len(wordsets), it.Total())
+	}
+
+	page, err := srv.Client().GetWordsets(context.Background(), 13, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.IsLastPage() || len(page.Data) != 10 {
+		t.Errorf("expected full last page, got %d wordsets, meta %+v", len(page.Data), page.Meta)
+	}
+}
+
+func TestClientGetMeaningChunks(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("big", meanings(120)...)
+	client := srv.Client()
+	words, err := client.GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := skyeng.Word{MeaningID: 100500}
+	requested := append([]skyeng.Word{missing}, words.Data...)
+
+	res, err := client.GetMeaning(context.Background(), requested...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Data) != 120 {
+		t.Fatalf("expected 120 meanings, got %d", len(res.Data))
+	}
+	for i, m := range res.Data {
+		if m.ID != words.Data[i].MeaningID {
+			t.Fatalf("meaning %d is out of order: %d != %d", i, m.ID, words.Data[i].MeaningID)
+		}
+	}
+	if len(res.Missing) != 1 || res.Missing[0] != missing.MeaningID {
+		t.Errorf("expected missing %d, got %v", missing.MeaningID, res.Missing)
+	}
+	if n := srv.Requests("/for-services/v2/meanings"); n != 3 {
+		t.Errorf("expected 3 chunk requests, got %d", n)
+	}
+
+	_, err = client.GetMeaning(context.Background(), missing)
+	if !errors.Is(err, skyeng.ErrMeaningNotFound) {
+		t.Errorf("expected ErrMeaningNotFound, got %v", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("ws", meanings(1)...)
+	client := srv.Client(fastRetries)
+
+	srv.FailNext(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	_, err := client.GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+
+	srv.FailNext(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	_, err = client.GetWords(context.Background(), ws)
+	if !errors.Is(err, skyeng.ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrUpstreamUnavailable, got %v", err)
+	}
+
+	srv.FailNext(http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	_, err = client.GetWords(context.Background(), ws)
+	if !errors.Is(err, skyeng.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+
+	srv.FailNext(http.StatusBadRequest)
+	_, err = client.GetWords(context.Background(), ws)
+	var statusErr *skyeng.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request status error, got %v", err)
+	}
+}
+
+func TestClientWritesAreNotRetried(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	m := srv.AddMeaning(skyeng.Meaning{Text: "decision"})
+	ws := srv.AddWordset("ws")
+	client := srv.Client(fastRetries)
+
+	srv.FailNext(http.StatusServiceUnavailable)
+	_, err := client.CreateWordset(context.Background(), "new")
+	if !errors.Is(err, skyeng.ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrUpstreamUnavailable, got %v", err)
+	}
+	if n := srv.Requests("/v1/wordsets.json"); n != 1 {
+		t.Errorf("expected single create request, got %d requests", n)
+	}
+
+	srv.FailNext(http.StatusBadGateway)
+	err = client.AddMeaning(context.Background(), ws.ID, m.ID)
+	if !errors.Is(err, skyeng.ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrUpstreamUnavailable, got %v", err)
+	}
+	if n := srv.Requests(fmt.Sprintf("/v1/wordsets/%d/words.json", ws.ID)); n != 1 {
+		t.Errorf("expected single add request, got %d requests", n)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := srv.Client().GetWordsets(ctx, 1, 10)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("ws", meanings(1)...)
+	dir, err := ioutil.TempDir("", "skyeng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := skyeng.NewFileTokenStore(filepath.Join(dir, "session.yaml"))
+	client := srv.Client(skyeng.WithTokenStore(store))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetWords(context.Background(), ws)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if srv.Logins() != 1 {
+		t.Fatalf("expected concurrent callers to share login, got %d logins", srv.Logins())
+	}
+
+	// Rejected token is refreshed by session cookie without login.
+	srv.ExpireTokens()
+	_, err = client.GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Logins() != 1 {
+		t.Errorf("expected token refresh without login, got %d logins", srv.Logins())
+	}
+
+	// Restarted client reuses stored session.
+	_, err = srv.Client(skyeng.WithTokenStore(store)).GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Logins() != 1 {
+		t.Errorf("expected stored session reuse, got %d logins", srv.Logins())
+	}
+}
+
+func TestClientWrongCredentials(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+
+	_, err := skyeng.NewClient("user", "wrong",
+		skyeng.WithEndpoints(srv.AuthEndpoint(), srv.WordsEndpoint(), srv.DictEndpoint()),
+	).GetWordsets(context.Background(), 1, 10)
+	if err == nil {
+		t.Fatal("expected login error")
+	}
+}
+
+func TestClientWriteOperations(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	m := srv.AddMeaning(skyeng.Meaning{Text: "decision"})
+	client := srv.Client()
+
+	ws, err := client.CreateWordset(context.Background(), "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.ID == 0 || ws.Title != "new" {
+		t.Fatalf("unexpected wordset %+v", ws)
+	}
+	err = client.AddMeaning(context.Background(), ws.ID, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := srv.Words(ws.ID); len(words) != 1 || words[0].MeaningID != m.ID {
+		t.Fatalf("meaning is not added, words %+v", words)
+	}
+	err = client.RemoveMeaning(context.Background(), ws.ID, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := srv.Words(ws.ID); len(words) != 0 {
+		t.Fatalf("meaning is not removed, words %+v", words)
+	}
+	err = client.RemoveMeaning(context.Background(), ws.ID, m.ID)
+	if !errors.Is(err, skyeng.ErrMeaningNotFound) {
+		t.Errorf("expected ErrMeaningNotFound, got %v", err)
+	}
+}
+
+func TestClientSearchAndProgress(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	m := srv.AddMeaning(skyeng.Meaning{Text: "decision", Translation: skyeng.Translation{Text: "решение"}})
+	srv.AddMeaning(skyeng.Meaning{Text: "postpone"})
+	srv.SetProgress(skyeng.WordProgress{MeaningID: m.ID, IsLearned: true, Progress: 100})
+	client := srv.Client()
+
+	results, err := client.Search(context.Background(), "decis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Meanings) != 1 || results[0].Meanings[0].ID != m.ID {
+		t.Fatalf("unexpected search results %+v", results)
+	}
+
+	progress, err := client.GetProgress(context.Background(), skyeng.Word{MeaningID: m.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 1 || !progress[0].IsLearned {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
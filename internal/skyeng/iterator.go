@@ -0,0 +1,88 @@
+package skyeng
+
+import (
+	"context"
+
+	errs "github.com/pkg/errors"
+)
+
+// WordsetIterator walks through all user wordsets page by page.
+type WordsetIterator struct {
+	ctx      context.Context
+	client   Client
+	pageSize int
+	page     int
+	meta     Meta
+	buf      []Wordset
+	current  Wordset
+	done     bool
+	err      error
+}
+
+// AllWordsets returns iterator over all wordsets of the client following pagination to the end.
+func AllWordsets(ctx context.Context, c Client) *WordsetIterator {
+	return &WordsetIterator{
+		ctx:      ctx,
+		client:   c,
+		pageSize: resultMaxPageSize,
+	}
+}
+
+// Next advances iterator to the next wordset. It returns false when wordsets are over or an error occurred.
+func (it *WordsetIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = errs.WithStack(err)
+			return false
+		}
+		it.page++
+		wordsets, err := it.client.GetWordsets(it.ctx, it.page, it.pageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.meta = wordsets.Meta
+		it.buf = wordsets.Data
+		it.done = isLastPage(wordsets.Meta, it.page, len(wordsets.Data))
+	}
+	it.current = it.buf[0]
+	it.buf = it.buf[1:]
+
+	return true
+}
+
+// Wordset returns current wordset.
+func (it *WordsetIterator) Wordset() Wordset {
+	return it.current
+}
+
+// Err returns error occurred during iteration.
+func (it *WordsetIterator) Err() error {
+	return it.err
+}
+
+// PageSize returns size of the pages requested by iterator.
+func (it *WordsetIterator) PageSize() int {
+	return it.pageSize
+}
+
+// Total returns total count of wordsets reported by server, it is known after the first Next call.
+func (it *WordsetIterator) Total() int {
+	return it.meta.Total
+}
+
+// Collect drains iterator and returns all remaining wordsets.
+func (it *WordsetIterator) Collect() ([]Wordset, error) {
+	var wordsets []Wordset
+	for it.Next() {
+		wordsets = append(wordsets, it.Wordset())
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	return wordsets, nil
+}
@@ -0,0 +1,50 @@
+package skyeng
+
+import "strings"
+
+// partsOfSpeech maps dictionary part of speech codes to names.
+var partsOfSpeech = map[string]string{
+	"n":   "noun",
+	"v":   "verb",
+	"j":   "adjective",
+	"r":   "adverb",
+	"prp": "preposition",
+	"prn": "pronoun",
+	"crd": "cardinal number",
+	"ord": "ordinal number",
+	"cjc": "conjunction",
+	"exc": "interjection",
+	"det": "article",
+	"abb": "abbreviation",
+	"x":   "particle",
+	"md":  "modal verb",
+	"ph":  "phrase",
+	"phi": "idiom",
+}
+
+// PartOfSpeech returns human readable part of speech, the code itself is returned for unknown codes.
+func (m Meaning) PartOfSpeech() string {
+	if name, ok := partsOfSpeech[m.PartOfSpeechCode]; ok {
+		return name
+	}
+
+	return m.PartOfSpeechCode
+}
+
+// ImageURL returns absolute URL of the first meaning image or empty string.
+func (m Meaning) ImageURL() string {
+	if len(m.Images) == 0 {
+		return ""
+	}
+
+	return AbsoluteURL(m.Images[0].URL)
+}
+
+// AbsoluteURL adds https scheme to protocol relative URLs returned by dictionary api.
+func AbsoluteURL(u string) string {
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+
+	return u
+}
@@ -0,0 +1,53 @@
+package skyeng
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMeaningDecodeRecordedResponse(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/meanings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meanings []Meaning
+	err = json.Unmarshal(raw, &meanings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meanings) != 2 {
+		t.Fatalf("expected 2 meanings, got %d", len(meanings))
+	}
+
+	m := meanings[0]
+	if m.ID != 211138 || m.WordID != 1407 || m.DifficultyLevel != 3 {
+		t.Errorf("unexpected ids: %+v", m)
+	}
+	if m.PartOfSpeech() != "noun" {
+		t.Errorf("expected noun, got %q", m.PartOfSpeech())
+	}
+	if m.Translation.Text != "решение" || m.Translation.Note != "выбор" {
+		t.Errorf("unexpected translation: %+v", m.Translation)
+	}
+	if m.SoundURL == "" || m.Definition.SoundURL == "" || m.Examples[0].SoundURL == "" {
+		t.Errorf("sound urls are not decoded: %+v", m)
+	}
+	if got := m.ImageURL(); got != "https://d2zkmv5t5kao9.cloudfront.net/images/6a6a4b5b2d0ce6d8e8b8d7b2a26dfe5b.jpeg?w=200&h=150" {
+		t.Errorf("unexpected image url %q", got)
+	}
+	if len(m.AlternativeTranslations) != 2 || m.AlternativeTranslations[0].Text != "resolution" {
+		t.Errorf("unexpected alternative translations: %+v", m.AlternativeTranslations)
+	}
+	if len(m.MeaningsWithSimilarTranslation) != 2 || m.MeaningsWithSimilarTranslation[1].MeaningID != 211139 {
+		t.Errorf("unexpected similar meanings: %+v", m.MeaningsWithSimilarTranslation)
+	}
+	if m.MeaningsWithSimilarTranslation[0].FrequencyPercent.String() != "68.0" {
+		t.Errorf("unexpected frequency %q", m.MeaningsWithSimilarTranslation[0].FrequencyPercent)
+	}
+
+	m = meanings[1]
+	if m.DifficultyLevel != 0 || m.PartOfSpeech() != "verb" || m.ImageURL() != "" {
+		t.Errorf("unexpected second meaning: %+v", m)
+	}
+}
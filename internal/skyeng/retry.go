@@ -0,0 +1,147 @@
+package skyeng
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	errs "github.com/pkg/errors"
+)
+
+var ErrRateLimited = errors.New("rate limited")
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+var ErrNotFound = errors.New("not found")
+
+// maxErrorBodyLen limits size of the response body kept in StatusError.
+const maxErrorBodyLen = 512
+
+// RetryPolicy configures retries of failed skyeng api requests.
+type RetryPolicy struct {
+	// MaxAttempts is a total count of attempts including the first one.
+	MaxAttempts int
+	// BaseDelay is a delay before the first retry, it doubles on every next retry.
+	BaseDelay time.Duration
+	// MaxDelay limits delay between retries, Retry-After header may exceed it.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns retry policy used by client by default.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: maxHttpRetries,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// WithRetryPolicy sets retry policy of the client, zero fields are taken from DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *client) {
+		def := DefaultRetryPolicy()
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = def.MaxAttempts
+		}
+		if policy.BaseDelay <= 0 {
+			policy.BaseDelay = def.BaseDelay
+		}
+		if policy.MaxDelay <= 0 {
+			policy.MaxDelay = def.MaxDelay
+		}
+		c.retry = policy
+	}
+}
+
+// backoff returns delay before the given retry, retries are numbered from 1.
+func (p RetryPolicy) backoff(retry int, err error) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// Equal jitter keeps delay within [delay/2, delay).
+	half := delay / 2
+	delay = half + time.Duration(rand.Int63n(int64(half)+1))
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+
+	return delay
+}
+
+// StatusError represents unexpected response status of skyeng api.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	if len(body) > maxErrorBodyLen {
+		body = body[:maxErrorBodyLen]
+	}
+
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %d, body %q", e.StatusCode, e.Body)
+}
+
+// Unwrap allows to match status error with errors.Is against ErrRateLimited, ErrUpstreamUnavailable and ErrNotFound.
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrUpstreamUnavailable
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// isRetryable reports whether request failed with transient error.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUpstreamUnavailable)
+}
+
+// sleep waits for the delay or context cancellation.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return errs.WithStack(ctx.Err())
+	}
+}
@@ -0,0 +1,222 @@
+package skyeng
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	errs "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// tokenRefreshLeeway is how long before expiry the token is refreshed.
+const tokenRefreshLeeway = 5 * time.Minute
+
+// Session represents authorized skyeng session.
+type Session struct {
+	// Token is a jwt taken from token_global cookie.
+	Token string `yaml:"token"`
+	// ExpiresAt is a token expiry, zero if token has no expiry claim.
+	ExpiresAt time.Time `yaml:"expires_at"`
+	// Cookies are login cookies allowing to issue new token without credentials.
+	Cookies map[string]string `yaml:"cookies"`
+}
+
+func (s *Session) valid(at time.Time) bool {
+	if s == nil || s.Token == "" {
+		return false
+	}
+
+	return s.ExpiresAt.IsZero() || s.ExpiresAt.After(at)
+}
+
+func (s *Session) cookie(name string) string {
+	if s == nil {
+		return ""
+	}
+
+	return s.Cookies[name]
+}
+
+// TokenStore persists skyeng session between restarts.
+type TokenStore interface {
+	// Load returns stored session or nil if there is nothing stored.
+	Load() (*Session, error)
+	Save(session *Session) error
+}
+
+// WithTokenStore sets store used to persist session.
+func WithTokenStore(store TokenStore) Option {
+	return func(c *client) {
+		if store != nil {
+			c.tokenStore = store
+		}
+	}
+}
+
+type noopTokenStore struct{}
+
+func (noopTokenStore) Load() (*Session, error) {
+	return nil, nil
+}
+
+func (noopTokenStore) Save(*Session) error {
+	return nil
+}
+
+// NewFileTokenStore returns TokenStore keeping session in yaml file.
+func NewFileTokenStore(filePath string) TokenStore {
+	return &fileTokenStore{
+		filePath: filePath,
+	}
+}
+
+type fileTokenStore struct {
+	filePath string
+}
+
+func (s *fileTokenStore) Load() (*Session, error) {
+	rawData, err := ioutil.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	if len(rawData) == 0 {
+		return nil, nil
+	}
+	var session Session
+	err = yaml.Unmarshal(rawData, &session)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+
+	return &session, nil
+}
+
+func (s *fileTokenStore) Save(session *Session) error {
+	yamlData, err := yaml.Marshal(session)
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	err = ioutil.WriteFile(s.filePath, yamlData, 0600)
+	if err != nil {
+		return errs.WithStack(err)
+	}
+
+	return nil
+}
+
+// accessToken returns valid token, logging in if needed. Concurrent callers share one login.
+func (c *client) accessToken(ctx context.Context) (string, error) {
+	c.sessionOnce.Do(c.loadSession)
+	c.sessionMx.Lock()
+	session := c.session
+	c.sessionMx.Unlock()
+	if session.valid(time.Now().Add(tokenRefreshLeeway)) {
+		return session.Token, nil
+	}
+
+	ch := c.authGroup.DoChan("auth", func() (interface{}, error) {
+		return c.renewSession()
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(*Session).Token, nil
+	case <-ctx.Done():
+		return "", errs.WithStack(ctx.Err())
+	}
+}
+
+// renewSession issues new token by session cookie and falls back to login with credentials.
+// It is not bound to the caller context because its result is shared between callers.
+func (c *client) renewSession() (*Session, error) {
+	c.sessionMx.Lock()
+	current := c.session
+	c.sessionMx.Unlock()
+	if current.valid(time.Now().Add(tokenRefreshLeeway)) {
+		return current, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	var session *Session
+	if sessionGlbl := current.cookie("session_global"); sessionGlbl != "" {
+		var err error
+		session, err = c.refreshToken(ctx, sessionGlbl)
+		if err != nil {
+			logrus.Warn("failed to refresh skyeng token, logging in, got ", err)
+		}
+	}
+	if session == nil {
+		var err error
+		session, err = c.auth(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.sessionMx.Lock()
+	c.session = session
+	c.sessionMx.Unlock()
+	err := c.tokenStore.Save(session)
+	if err != nil {
+		logrus.Error("failed to save skyeng session, got ", err)
+	}
+
+	return session, nil
+}
+
+// invalidateToken drops rejected token keeping session cookies for refresh.
+func (c *client) invalidateToken(token string) {
+	c.sessionMx.Lock()
+	defer c.sessionMx.Unlock()
+	if c.session != nil && c.session.Token == token {
+		c.session = &Session{
+			Cookies: c.session.Cookies,
+		}
+	}
+}
+
+func (c *client) loadSession() {
+	session, err := c.tokenStore.Load()
+	if err != nil {
+		logrus.Error("failed to load skyeng session, got ", err)
+		return
+	}
+	c.sessionMx.Lock()
+	defer c.sessionMx.Unlock()
+	if c.session == nil {
+		c.session = session
+	}
+}
+
+// tokenExpiry decodes exp claim of the jwt, it returns zero time if token has no expiry.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,415 @@
+// Package skyengtest provides fake skyeng api for hermetic tests.
+package skyengtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+)
+
+// defaultPageSize is used when request has no pageSize parameter.
+const defaultPageSize = 20
+
+var (
+	wordsetWordsPath = regexp.MustCompile(`^/v1/wordsets/(\d+)/words\.json$`)
+	wordsetWordPath  = regexp.MustCompile(`^/v1/wordsets/(\d+)/words/(\d+)\.json$`)
+)
+
+// Server is a fake skyeng api emulating login flow, wordsets, words and dictionary endpoints.
+type Server struct {
+	*httptest.Server
+	// TokenTTL is a lifetime of issued tokens.
+	TokenTTL time.Duration
+
+	user     string
+	password string
+	mx       sync.Mutex
+	nextID   int
+	wordsets []skyeng.Wordset
+	words    map[int][]skyeng.Word
+	meanings map[int]skyeng.Meaning
+	progress map[int]skyeng.WordProgress
+	sessions map[string]bool
+	tokens   map[string]time.Time
+	failures []int
+	logins   int
+	requests map[string]int
+}
+
+// NewServer starts fake skyeng api accepting given credentials.
+func NewServer(user string, password string) *Server {
+	s := &Server{
+		TokenTTL: time.Hour,
+		user:     user,
+		password: password,
+		nextID:   1,
+		words:    make(map[int][]skyeng.Word),
+		meanings: make(map[int]skyeng.Meaning),
+		progress: make(map[int]skyeng.WordProgress),
+		sessions: make(map[string]bool),
+		tokens:   make(map[string]time.Time),
+		requests: make(map[string]int),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/en/frame/login", s.loginFrame)
+	mux.HandleFunc("/auth/en/frame/login-submit", s.loginSubmit)
+	mux.HandleFunc("/auth/user-api/v1/auth/jwt", s.jwt)
+	mux.Handle("/words/api/", http.StripPrefix("/words/api", s.authorized(http.HandlerFunc(s.wordsAPI))))
+	mux.Handle("/dict/api/", http.StripPrefix("/dict/api", s.authorized(http.HandlerFunc(s.dictAPI))))
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// AuthEndpoint returns endpoint to pass to skyeng.WithEndpoints.
+func (s *Server) AuthEndpoint() string {
+	return s.URL + "/auth"
+}
+
+// WordsEndpoint returns endpoint to pass to skyeng.WithEndpoints.
+func (s *Server) WordsEndpoint() string {
+	return s.URL + "/words/api"
+}
+
+// DictEndpoint returns endpoint to pass to skyeng.WithEndpoints.
+func (s *Server) DictEndpoint() string {
+	return s.URL + "/dict/api"
+}
+
+// Client returns skyeng client configured to use the server.
+func (s *Server) Client(opts ...skyeng.Option) skyeng.Client {
+	opts = append([]skyeng.Option{skyeng.WithEndpoints(s.AuthEndpoint(), s.WordsEndpoint(), s.DictEndpoint())}, opts...)
+
+	return skyeng.NewClient(s.user, s.password, opts...)
+}
+
+// AddWordset adds wordset containing the meanings, meanings without ID get generated one.
+func (s *Server) AddWordset(title string, meanings ...skyeng.Meaning) skyeng.Wordset {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	ws := skyeng.Wordset{ID: s.id(), Title: title}
+	s.wordsets = append(s.wordsets, ws)
+	for _, m := range meanings {
+		if m.ID == 0 {
+			m.ID = s.id()
+		}
+		s.meanings[m.ID] = m
+		s.words[ws.ID] = append(s.words[ws.ID], skyeng.Word{ID: s.id(), MeaningID: m.ID})
+	}
+
+	return ws
+}
+
+// AddMeaning adds meaning to the dictionary without adding it to any wordset.
+func (s *Server) AddMeaning(m skyeng.Meaning) skyeng.Meaning {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if m.ID == 0 {
+		m.ID = s.id()
+	}
+	s.meanings[m.ID] = m
+
+	return m
+}
+
+// SetProgress sets training progress of the meaning.
+func (s *Server) SetProgress(p skyeng.WordProgress) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.progress[p.MeaningID] = p
+}
+
+// Words returns words of the wordset.
+func (s *Server) Words(wordsetID int) []skyeng.Word {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return append([]skyeng.Word(nil), s.words[wordsetID]...)
+}
+
+// FailNext makes next api requests respond with the statuses in order.
+func (s *Server) FailNext(statuses ...int) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// ExpireTokens invalidates all issued tokens, login sessions stay valid.
+func (s *Server) ExpireTokens() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.tokens = make(map[string]time.Time)
+}
+
+// Logins returns count of successful logins with credentials.
+func (s *Server) Logins() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return s.logins
+}
+
+// Requests returns count of api requests to the path, e.g. "/for-services/v2/meanings".
+func (s *Server) Requests(path string) int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return s.requests[path]
+}
+
+func (s *Server) id() int {
+	s.nextID++
+
+	return s.nextID
+}
+
+func (s *Server) loginFrame(w http.ResponseWriter, req *http.Request) {
+	s.mx.Lock()
+	session := fmt.Sprintf("anonymous-%d", s.id())
+	s.mx.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: "session_global", Value: session})
+	fmt.Fprintf(w, `<form><input type="hidden" name="csrfToken" value="csrf-%s"></form>`, session)
+}
+
+func (s *Server) loginSubmit(w http.ResponseWriter, req *http.Request) {
+	cookie, err := req.Cookie("session_global")
+	if err != nil || req.PostFormValue("csrfToken") != "csrf-"+cookie.Value {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if req.PostFormValue("username") != s.user || req.PostFormValue("password") != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mx.Lock()
+	session := fmt.Sprintf("session-%d", s.id())
+	s.sessions[session] = true
+	s.logins++
+	s.mx.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: "session_global", Value: session})
+}
+
+func (s *Server) jwt(w http.ResponseWriter, req *http.Request) {
+	cookie, err := req.Cookie("session_global")
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err != nil || !s.sessions[cookie.Value] {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	expiresAt := time.Now().Add(s.TokenTTL)
+	payload, _ := json.Marshal(map[string]int64{"exp": expiresAt.Unix(), "jti": int64(s.id())})
+	token := strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)),
+		base64.RawURLEncoding.EncodeToString(payload),
+		"signature",
+	}, ".")
+	s.tokens[token] = expiresAt
+	http.SetCookie(w, &http.Cookie{Name: "token_global", Value: token})
+}
+
+func (s *Server) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mx.Lock()
+		s.requests[req.URL.Path]++
+		if len(s.failures) > 0 {
+			status := s.failures[0]
+			s.failures = s.failures[1:]
+			s.mx.Unlock()
+			w.WriteHeader(status)
+			return
+		}
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		expiresAt, ok := s.tokens[token]
+		s.mx.Unlock()
+		if !ok || time.Now().After(expiresAt) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (s *Server) wordsAPI(w http.ResponseWriter, req *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	path := req.URL.Path
+	switch {
+	case path == "/for-vimbox/v1/wordsets.json" && req.Method == http.MethodGet:
+		data, meta := paginate(req, len(s.wordsets))
+		writeJSON(w, map[string]interface{}{"data": s.wordsets[data[0]:data[1]], "meta": meta})
+	case path == "/v1/wordsets.json" && req.Method == http.MethodPost:
+		var body struct {
+			Title string `json:"title"`
+		}
+		if json.NewDecoder(req.Body).Decode(&body) != nil || body.Title == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ws := skyeng.Wordset{ID: s.id(), Title: body.Title}
+		s.wordsets = append(s.wordsets, ws)
+		writeJSON(w, map[string]interface{}{"data": ws})
+	case path == "/v1/words/progress.json" && req.Method == http.MethodGet:
+		var progress []skyeng.WordProgress
+		for _, id := range parseIDs(req.URL.Query().Get("meaningIds")) {
+			p, ok := s.progress[id]
+			if !ok {
+				p = skyeng.WordProgress{MeaningID: id}
+			}
+			progress = append(progress, p)
+		}
+		writeJSON(w, map[string]interface{}{"data": progress})
+	case wordsetWordsPath.MatchString(path):
+		wordsetID, _ := strconv.Atoi(wordsetWordsPath.FindStringSubmatch(path)[1])
+		if !s.hasWordset(wordsetID) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			words := s.words[wordsetID]
+			data, meta := paginate(req, len(words))
+			writeJSON(w, map[string]interface{}{"data": words[data[0]:data[1]], "meta": meta})
+		case http.MethodPost:
+			var body struct {
+				MeaningID int `json:"meaningId"`
+			}
+			if json.NewDecoder(req.Body).Decode(&body) != nil || body.MeaningID == 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.words[wordsetID] = append(s.words[wordsetID], skyeng.Word{ID: s.id(), MeaningID: body.MeaningID})
+			writeJSON(w, map[string]interface{}{})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case wordsetWordPath.MatchString(path) && req.Method == http.MethodDelete:
+		match := wordsetWordPath.FindStringSubmatch(path)
+		wordsetID, _ := strconv.Atoi(match[1])
+		meaningID, _ := strconv.Atoi(match[2])
+		words := s.words[wordsetID]
+		for i, word := range words {
+			if word.MeaningID == meaningID {
+				s.words[wordsetID] = append(words[:i:i], words[i+1:]...)
+				writeJSON(w, map[string]interface{}{})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) dictAPI(w http.ResponseWriter, req *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	switch req.URL.Path {
+	case "/for-services/v2/meanings":
+		meanings := []skyeng.Meaning{}
+		for _, id := range parseIDs(req.URL.Query().Get("ids")) {
+			if m, ok := s.meanings[id]; ok {
+				meanings = append(meanings, m)
+			}
+		}
+		writeJSON(w, meanings)
+	case "/public/v1/words/search":
+		query := strings.ToLower(req.URL.Query().Get("search"))
+		byText := make(map[string]*skyeng.SearchResult)
+		var texts []string
+		for _, m := range s.meanings {
+			if query == "" || !strings.Contains(strings.ToLower(m.Text), query) {
+				continue
+			}
+			r, ok := byText[m.Text]
+			if !ok {
+				r = &skyeng.SearchResult{ID: m.WordID, Text: m.Text}
+				byText[m.Text] = r
+				texts = append(texts, m.Text)
+			}
+			r.Meanings = append(r.Meanings, skyeng.SearchMeaning{
+				ID:               m.ID,
+				PartOfSpeechCode: m.PartOfSpeechCode,
+				Translation:      m.Translation,
+				Transcription:    m.Transcription,
+				SoundURL:         m.SoundURL,
+			})
+		}
+		sort.Strings(texts)
+		results := []skyeng.SearchResult{}
+		for _, text := range texts {
+			results = append(results, *byText[text])
+		}
+		writeJSON(w, results)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) hasWordset(wordsetID int) bool {
+	for _, ws := range s.wordsets {
+		if ws.ID == wordsetID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// paginate returns bounds of the requested page and its metadata.
+func paginate(req *http.Request, total int) ([2]int, skyeng.Meta) {
+	page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(req.URL.Query().Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	lastPage := (total + pageSize - 1) / pageSize
+	if lastPage == 0 {
+		lastPage = 1
+	}
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return [2]int{start, end}, skyeng.Meta{
+		CurrentPage: page,
+		LastPage:    lastPage,
+		PageSize:    pageSize,
+		Total:       total,
+	}
+}
+
+func parseIDs(value string) []int {
+	var ids []int
+	for _, raw := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(raw)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,430 @@
+package skyeng
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	errs "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrOffline = errors.New("operation is not available offline")
+
+// Snapshot represents local copy of user skyeng content.
+type Snapshot struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Wordsets  []Wordset `json:"wordsets"`
+	// Words are words of the wordsets by wordset ID.
+	Words    map[int][]Word       `json:"words"`
+	Meanings map[int]Meaning      `json:"meanings"`
+	Progress map[int]WordProgress `json:"progress"`
+}
+
+// TakeSnapshot downloads all wordsets, words, meanings and progress of the user.
+// Wordsets and meanings removed on skyeng side during download are skipped.
+func TakeSnapshot(ctx context.Context, c Client) (*Snapshot, error) {
+	wordsets, err := AllWordsets(ctx, c).Collect()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{
+		CreatedAt: time.Now(),
+		Wordsets:  wordsets,
+		Words:     make(map[int][]Word, len(wordsets)),
+		Meanings:  make(map[int]Meaning),
+		Progress:  make(map[int]WordProgress),
+	}
+	var allWords []Word
+	for _, ws := range wordsets {
+		words, err := c.GetWords(ctx, ws)
+		if errors.Is(err, ErrWordsetNotFound) {
+			logrus.Warnf("wordset %d is not found, skipped in snapshot", ws.ID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Words[ws.ID] = words.Data
+		allWords = append(allWords, words.Data...)
+	}
+	if len(allWords) == 0 {
+		return snapshot, nil
+	}
+
+	meanings, err := c.GetMeaning(ctx, allWords...)
+	if errors.Is(err, ErrMeaningNotFound) {
+		logrus.Warn("meanings are not found, skipped in snapshot, got ", err)
+		meanings = &MeaningsData{}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(meanings.Missing) > 0 {
+		logrus.Warnf("meanings %v are not found, skipped in snapshot", meanings.Missing)
+	}
+	for _, m := range meanings.Data {
+		snapshot.Meanings[m.ID] = m
+	}
+	progress, err := c.GetProgress(ctx, allWords...)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range progress {
+		snapshot.Progress[p.MeaningID] = p
+	}
+
+	return snapshot, nil
+}
+
+// SaveSnapshot atomically writes snapshot to the file.
+func SaveSnapshot(filePath string, snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filePath), filepath.Base(filePath)+".*")
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return errs.WithStack(err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	err = os.Rename(tmp.Name(), filePath)
+	if err != nil {
+		return errs.WithStack(err)
+	}
+
+	return nil
+}
+
+// LoadSnapshot reads snapshot from the file.
+func LoadSnapshot(filePath string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	var snapshot Snapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+
+	return &snapshot, nil
+}
+
+// SnapshotSyncer periodically saves snapshot of the client content.
+type SnapshotSyncer struct {
+	client   Client
+	filePath string
+	interval time.Duration
+}
+
+// NewSnapshotSyncer returns SnapshotSyncer.
+func NewSnapshotSyncer(c Client, filePath string, interval time.Duration) *SnapshotSyncer {
+	return &SnapshotSyncer{
+		client:   c,
+		filePath: filePath,
+		interval: interval,
+	}
+}
+
+// Sync takes and saves snapshot once.
+func (s *SnapshotSyncer) Sync(ctx context.Context) error {
+	snapshot, err := TakeSnapshot(ctx, s.client)
+	if err != nil {
+		return err
+	}
+
+	return SaveSnapshot(s.filePath, snapshot)
+}
+
+// Run syncs snapshot on start and then every interval until context is done.
+func (s *SnapshotSyncer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		err := s.Sync(ctx)
+		if err != nil && ctx.Err() == nil {
+			logrus.Error("failed to sync skyeng snapshot, got ", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NewSnapshotClient returns read only Client serving content from the snapshot file.
+// The file is reread when it changes.
+func NewSnapshotClient(filePath string) Client {
+	return &snapshotClient{
+		filePath: filePath,
+	}
+}
+
+type snapshotClient struct {
+	filePath string
+	mx       sync.Mutex
+	snapshot *Snapshot
+	modTime  time.Time
+}
+
+func (c *snapshotClient) load() (*Snapshot, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	info, err := os.Stat(c.filePath)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	if c.snapshot != nil && info.ModTime().Equal(c.modTime) {
+		return c.snapshot, nil
+	}
+	snapshot, err := LoadSnapshot(c.filePath)
+	if err != nil {
+		return nil, err
+	}
+	c.snapshot = snapshot
+	c.modTime = info.ModTime()
+
+	return snapshot, nil
+}
+
+func (c *snapshotClient) GetWordsets(ctx context.Context, page int, pageSize int) (*WordsetsData, error) {
+	snapshot, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > resultMaxPageSize {
+		pageSize = resultMaxPageSize
+	}
+	total := len(snapshot.Wordsets)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	lastPage := (total + pageSize - 1) / pageSize
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	return &WordsetsData{
+		Data: snapshot.Wordsets[start:end],
+		Meta: Meta{
+			CurrentPage: page,
+			LastPage:    lastPage,
+			PageSize:    pageSize,
+			Total:       total,
+		},
+	}, nil
+}
+
+func (c *snapshotClient) GetWords(ctx context.Context, ws Wordset) (*WordsData, error) {
+	snapshot, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	words, ok := snapshot.Words[ws.ID]
+	if !ok {
+		return nil, errs.Wrapf(ErrWordsetNotFound, "wordsetID: %d", ws.ID)
+	}
+
+	return &WordsData{
+		Data: words,
+		Meta: Meta{
+			CurrentPage: 1,
+			LastPage:    1,
+			PageSize:    len(words),
+			Total:       len(words),
+		},
+	}, nil
+}
+
+func (c *snapshotClient) GetMeaning(ctx context.Context, words ...Word) (*MeaningsData, error) {
+	snapshot, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	ids := uniqueMeaningIDs(words)
+	var meanings MeaningsData
+	for _, id := range ids {
+		m, ok := snapshot.Meanings[id]
+		if !ok {
+			meanings.Missing = append(meanings.Missing, id)
+			continue
+		}
+		meanings.Data = append(meanings.Data, m)
+	}
+	if len(meanings.Data) == 0 {
+		return nil, errs.Wrapf(ErrMeaningNotFound, "meaningID: %v", ids)
+	}
+
+	return &meanings, nil
+}
+
+// Search finds snapshot meanings containing the query.
+func (c *snapshotClient) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	snapshot, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	var results []SearchResult
+	byText := make(map[string]int)
+	for _, m := range snapshot.Meanings {
+		if query == "" || !strings.Contains(strings.ToLower(m.Text), query) {
+			continue
+		}
+		i, ok := byText[m.Text]
+		if !ok {
+			i = len(results)
+			byText[m.Text] = i
+			results = append(results, SearchResult{ID: m.WordID, Text: m.Text})
+		}
+		results[i].Meanings = append(results[i].Meanings, SearchMeaning{
+			ID:               m.ID,
+			PartOfSpeechCode: m.PartOfSpeechCode,
+			Translation:      m.Translation,
+			ImageURL:         m.ImageURL(),
+			Transcription:    m.Transcription,
+			SoundURL:         m.SoundURL,
+		})
+		if len(results) == searchPageSize {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (c *snapshotClient) CreateWordset(ctx context.Context, title string) (*Wordset, error) {
+	return nil, errs.WithStack(ErrOffline)
+}
+
+func (c *snapshotClient) AddMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	return errs.WithStack(ErrOffline)
+}
+
+func (c *snapshotClient) RemoveMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	return errs.WithStack(ErrOffline)
+}
+
+func (c *snapshotClient) GetProgress(ctx context.Context, words ...Word) ([]WordProgress, error) {
+	snapshot, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	var progress []WordProgress
+	for _, id := range uniqueMeaningIDs(words) {
+		if p, ok := snapshot.Progress[id]; ok {
+			progress = append(progress, p)
+		}
+	}
+
+	return progress, nil
+}
+
+// NewFallbackClient returns Client reading from the fallback client when the primary one fails.
+// Write operations are never sent to the fallback client.
+func NewFallbackClient(primary Client, fallback Client) Client {
+	return &fallbackClient{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+type fallbackClient struct {
+	primary  Client
+	fallback Client
+}
+
+// shouldFallback reports whether the primary client failure is worth trying the fallback one.
+func shouldFallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	return !errors.Is(err, ErrWordsetNotFound) && !errors.Is(err, ErrMeaningNotFound)
+}
+
+func (c *fallbackClient) GetWordsets(ctx context.Context, page int, pageSize int) (*WordsetsData, error) {
+	wordsets, err := c.primary.GetWordsets(ctx, page, pageSize)
+	if err != nil && shouldFallback(ctx, err) {
+		logrus.Warn("skyeng is unavailable, serving wordsets from snapshot, got ", err)
+		return c.fallback.GetWordsets(ctx, page, pageSize)
+	}
+
+	return wordsets, err
+}
+
+func (c *fallbackClient) GetWords(ctx context.Context, ws Wordset) (*WordsData, error) {
+	words, err := c.primary.GetWords(ctx, ws)
+	if err != nil && shouldFallback(ctx, err) {
+		logrus.Warn("skyeng is unavailable, serving words from snapshot, got ", err)
+		return c.fallback.GetWords(ctx, ws)
+	}
+
+	return words, err
+}
+
+func (c *fallbackClient) GetMeaning(ctx context.Context, words ...Word) (*MeaningsData, error) {
+	meanings, err := c.primary.GetMeaning(ctx, words...)
+	if err != nil && shouldFallback(ctx, err) {
+		logrus.Warn("skyeng is unavailable, serving meanings from snapshot, got ", err)
+		return c.fallback.GetMeaning(ctx, words...)
+	}
+
+	return meanings, err
+}
+
+func (c *fallbackClient) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	results, err := c.primary.Search(ctx, query)
+	if err != nil && shouldFallback(ctx, err) {
+		logrus.Warn("skyeng is unavailable, searching in snapshot, got ", err)
+		return c.fallback.Search(ctx, query)
+	}
+
+	return results, err
+}
+
+func (c *fallbackClient) CreateWordset(ctx context.Context, title string) (*Wordset, error) {
+	return c.primary.CreateWordset(ctx, title)
+}
+
+func (c *fallbackClient) AddMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	return c.primary.AddMeaning(ctx, wordsetID, meaningID)
+}
+
+func (c *fallbackClient) RemoveMeaning(ctx context.Context, wordsetID int, meaningID int) error {
+	return c.primary.RemoveMeaning(ctx, wordsetID, meaningID)
+}
+
+func (c *fallbackClient) GetProgress(ctx context.Context, words ...Word) ([]WordProgress, error) {
+	progress, err := c.primary.GetProgress(ctx, words...)
+	if err != nil && shouldFallback(ctx, err) {
+		logrus.Warn("skyeng is unavailable, serving progress from snapshot, got ", err)
+		return c.fallback.GetProgress(ctx, words...)
+	}
+
+	return progress, err
+}
@@ -0,0 +1,89 @@
+package skyeng_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pachmu/skyeng-push-notificator/internal/skyeng/skyengtest"
+)
+
+// missingMeanings is Client which dictionary lost all meanings.
+type missingMeanings struct {
+	skyeng.Client
+}
+
+func (c missingMeanings) GetMeaning(ctx context.Context, words ...skyeng.Word) (*skyeng.MeaningsData, error) {
+	return nil, skyeng.ErrMeaningNotFound
+}
+
+func TestTakeSnapshotSkipsMissingMeanings(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("daily", meanings(2)...)
+
+	snapshot, err := skyeng.TakeSnapshot(context.Background(), missingMeanings{srv.Client(fastRetries)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Words[ws.ID]) != 2 || len(snapshot.Meanings) != 0 {
+		t.Errorf("expected words without meanings, got %+v", snapshot)
+	}
+}
+
+func TestSnapshotFallback(t *testing.T) {
+	srv := skyengtest.NewServer("user", "pass")
+	defer srv.Close()
+	ws := srv.AddWordset("daily", meanings(3)...)
+	dir, err := ioutil.TempDir("", "skyeng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	live := srv.Client(fastRetries)
+
+	err = skyeng.NewSnapshotSyncer(live, snapshotPath, 0).Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := skyeng.NewFallbackClient(live, skyeng.NewSnapshotClient(snapshotPath))
+
+	srv.FailNext(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	words, err := client.GetWords(context.Background(), ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words.Data) != 3 {
+		t.Fatalf("expected 3 words from snapshot, got %d", len(words.Data))
+	}
+
+	srv.FailNext(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	res, err := client.GetMeaning(context.Background(), words.Data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Data) != 3 || res.Data[0].Text != "word0" {
+		t.Fatalf("unexpected meanings from snapshot %+v", res.Data)
+	}
+
+	srv.FailNext(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	wordsets, err := skyeng.AllWordsets(context.Background(), client).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wordsets) != 1 || wordsets[0].ID != ws.ID {
+		t.Fatalf("unexpected wordsets from snapshot %+v", wordsets)
+	}
+
+	srv.FailNext(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	err = client.AddMeaning(context.Background(), ws.ID, res.Data[0].ID)
+	if !errors.Is(err, skyeng.ErrUpstreamUnavailable) {
+		t.Errorf("expected write to fail without fallback, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package srs
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Grade represents user answer to the reviewed word.
+type Grade int
+
+// Grades from forgotten to easily recalled word.
+const (
+	Again Grade = iota
+	Hard
+	Good
+	Easy
+)
+
+// Grades lists all grades in the order of buttons.
+var Grades = []Grade{Again, Hard, Good, Easy}
+
+var gradeNames = []string{"again", "hard", "good", "easy"}
+
+func (g Grade) String() string {
+	if g < Again || g > Easy {
+		return "unknown"
+	}
+	return gradeNames[g]
+}
+
+// Title returns grade button title.
+func (g Grade) Title() string {
+	name := g.String()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// ParseGrade returns grade of its name.
+func ParseGrade(name string) (Grade, error) {
+	for i, n := range gradeNames {
+		if n == strings.ToLower(name) {
+			return Grade(i), nil
+		}
+	}
+
+	return 0, errors.Errorf("unknown grade %q", name)
+}
+
+const (
+	defaultEase = 2.5
+	minEase     = 1.3
+	day         = 24 * time.Hour
+	// relearnInterval is an interval after forgotten word, it is shown again the same day.
+	relearnInterval = 10 * time.Minute
+	easyBonus       = 1.3
+	hardFactor      = 1.2
+)
+
+// Item represents SM-2 review state of the meaning.
+type Item struct {
+	// Ease multiplies interval after every successful review.
+	Ease        float64       `yaml:"ease"`
+	Interval    time.Duration `yaml:"interval"`
+	Repetitions int           `yaml:"repetitions"`
+	Lapses      int           `yaml:"lapses"`
+	Due         time.Time     `yaml:"due"`
+}
+
+// Review updates item according to the grade, the next review is due after the new interval.
+func (it *Item) Review(g Grade, now time.Time) {
+	if it.Ease == 0 {
+		it.Ease = defaultEase
+	}
+	switch g {
+	case Again:
+		it.Repetitions = 0
+		it.Lapses++
+		it.Ease = math.Max(minEase, it.Ease-0.2)
+		it.Interval = relearnInterval
+	case Hard:
+		it.Ease = math.Max(minEase, it.Ease-0.15)
+		it.Interval = maxDuration(day, time.Duration(float64(it.Interval)*hardFactor))
+	case Good, Easy:
+		it.Repetitions++
+		switch it.Repetitions {
+		case 1:
+			it.Interval = day
+		case 2:
+			it.Interval = 6 * day
+		default:
+			it.Interval = maxDuration(day, time.Duration(float64(it.Interval)*it.Ease))
+		}
+		if g == Easy {
+			it.Ease += 0.15
+			it.Interval = time.Duration(float64(it.Interval) * easyBonus)
+		}
+	}
+	it.Due = now.Add(it.Interval)
+}
+
+// MostDue returns the candidate meaning which review is the most overdue, never reviewed
+// meanings are due now. False is returned if there are no candidates.
+func MostDue(items map[int]Item, candidates []int, now time.Time) (int, bool) {
+	var best int
+	var bestDue time.Time
+	for i, id := range candidates {
+		due := now
+		if it, ok := items[id]; ok {
+			due = it.Due
+		}
+		if i == 0 || due.Before(bestDue) {
+			best, bestDue = id, due
+		}
+	}
+
+	return best, len(candidates) > 0
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
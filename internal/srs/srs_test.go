@@ -0,0 +1,76 @@
+package srs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReview(t *testing.T) {
+	now := time.Date(2020, 5, 8, 9, 0, 0, 0, time.UTC)
+	var it Item
+	steps := []struct {
+		grade    Grade
+		interval time.Duration
+	}{
+		{Good, day},
+		{Good, 6 * day},
+		{Good, 15 * day},
+		{Again, relearnInterval},
+		{Good, day},
+		{Hard, time.Duration(float64(day) * hardFactor)},
+	}
+	for i, step := range steps {
+		it.Review(step.grade, now)
+		if it.Interval != step.interval {
+			t.Fatalf("step %d: expected interval %s, got %s", i, step.interval, it.Interval)
+		}
+		if !it.Due.Equal(now.Add(step.interval)) {
+			t.Fatalf("step %d: expected due %s, got %s", i, now.Add(step.interval), it.Due)
+		}
+	}
+	if it.Lapses != 1 || it.Ease >= defaultEase {
+		t.Errorf("expected lapse to lower ease, got %+v", it)
+	}
+
+	easy := Item{}
+	easy.Review(Easy, now)
+	if easy.Interval != time.Duration(float64(day)*easyBonus) || easy.Ease <= defaultEase {
+		t.Errorf("expected easy bonus, got %+v", easy)
+	}
+
+	hard := Item{Ease: minEase}
+	hard.Review(Again, now)
+	if hard.Ease != minEase {
+		t.Errorf("expected ease not lower than %v, got %v", minEase, hard.Ease)
+	}
+}
+
+func TestMostDue(t *testing.T) {
+	now := time.Date(2020, 5, 8, 9, 0, 0, 0, time.UTC)
+	items := map[int]Item{
+		1: {Due: now.Add(time.Hour)},
+		2: {Due: now.Add(-time.Hour)},
+		3: {Due: now.Add(-2 * time.Hour)},
+	}
+	if id, ok := MostDue(items, []int{1, 2, 3}, now); !ok || id != 3 {
+		t.Errorf("expected the most overdue meaning 3, got %d", id)
+	}
+	if id, ok := MostDue(items, []int{1, 4}, now); !ok || id != 4 {
+		t.Errorf("expected never reviewed meaning 4, got %d", id)
+	}
+	if _, ok := MostDue(items, nil, now); ok {
+		t.Error("expected no meaning of empty candidates")
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	for _, g := range Grades {
+		parsed, err := ParseGrade(g.String())
+		if err != nil || parsed != g {
+			t.Errorf("failed to parse %s, got %v, %v", g, parsed, err)
+		}
+	}
+	if _, err := ParseGrade("perfect"); err == nil {
+		t.Error("expected unknown grade error")
+	}
+}
@@ -1,68 +1,303 @@
 package state
 
 import (
+	"context"
 	"sync"
 	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
+	"github.com/pachmu/skyeng-push-notificator/internal/schedule"
+	"github.com/pkg/errors"
+)
+
+// Source returns card to send, nil card means there is nothing to send.
+type Source func(ctx context.Context) (*notifier.Card, error)
+
+// Command is a sender control operation queued by State.
+type Command int
+
+// Commands applied by sender in the queue order.
+const (
+	// CommandSetSchedule resumes sending by the new schedule and sends immediately.
+	CommandSetSchedule Command = iota
+	CommandSuspend
+	CommandResume
+	CommandSetSource
+	// CommandTriggerNow sends card immediately keeping the schedule.
+	CommandTriggerNow
+	// CommandSkipNext drops the next scheduled send.
+	CommandSkipNext
+	// CommandReschedule recalculates the next send after quiet hours or do not disturb change.
+	CommandReschedule
 )
 
+// maxPendingCommands limits count of queued send now and skip next commands, other commands are merged.
+const maxPendingCommands = 64
+
+var commandNames = []string{"set schedule", "suspend", "resume", "set source", "trigger now", "skip next", "reschedule"}
+
+func (c Command) String() string {
+	if c < CommandSetSchedule || c > CommandReschedule {
+		return "unknown"
+	}
+	return commandNames[c]
+}
+
+// Status represents state resulting from the command. The command is queued, WaitApplied waits
+// until sender acknowledges it.
+type Status struct {
+	Schedule  schedule.Schedule
+	Suspended bool
+	// Pending is a number of commands not taken by sender yet.
+	Pending int
+	// Queued is false if the command is dropped because too many commands are pending.
+	Queued bool
+	// ID identifies the last queued command.
+	ID uint64
+	// Applied is set by callers waiting for the command to be applied by sender.
+	Applied bool
+}
+
+// State keeps sending settings, changes are applied immediately and queued as commands for sender.
+// Control methods never block and the mutex is never held during I/O or channel operations.
 type State struct {
-	timeInterval        time.Duration
-	sendWordsetCallback func() error
-	changeInterval      chan time.Duration
-	suspend             chan struct{}
-	mx                  sync.Mutex
+	schedule  schedule.Schedule
+	source    Source
+	suspended bool
+	commands  []Command
+	wake      chan struct{}
+	// lastID is ID of the last queued command, commands up to taken are taken by sender
+	// and commands up to applied are applied, applied channel is closed on every acknowledgement.
+	lastID     uint64
+	taken      uint64
+	applied    uint64
+	appliedCh  chan struct{}
+	quiet      *schedule.QuietHours
+	deferQuiet bool
+	dndUntil   time.Time
+	mx         sync.Mutex
 }
 
 func NewState(timeInterval time.Duration) *State {
 	return &State{
-		sendWordsetCallback: func() error {
-			return nil
+		source: func(ctx context.Context) (*notifier.Card, error) {
+			return nil, nil
 		},
-		timeInterval:   timeInterval * time.Minute,
-		changeInterval: make(chan time.Duration, 1),
-		suspend:        make(chan struct{}, 1),
+		schedule:  schedule.Every(timeInterval * time.Minute),
+		wake:      make(chan struct{}, 1),
+		appliedCh: make(chan struct{}),
 	}
 }
 
-func (s *State) GetTimeInterval() time.Duration {
+// GetSchedule returns current sending schedule.
+func (s *State) GetSchedule() schedule.Schedule {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.schedule
+}
+
+// GetStatus returns current state.
+func (s *State) GetStatus() Status {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	return s.timeInterval
+	return s.status()
 }
 
-func (s *State) GetChangeInterval() <-chan time.Duration {
-	return s.changeInterval
+func (s *State) status() Status {
+	return Status{
+		Schedule:  s.schedule,
+		Suspended: s.suspended,
+		Pending:   len(s.commands),
+		ID:        s.lastID,
+	}
 }
 
-func (s *State) GetSuspendWork() <-chan struct{} {
-	return s.suspend
+// Commands returns channel notified when commands are queued, pending notifications are merged.
+func (s *State) Commands() <-chan struct{} {
+	return s.wake
 }
 
-func (s *State) WordsetCallback() error {
+// TakeCommands returns queued commands in order and empties the queue,
+// sender calls Applied once the commands are applied.
+func (s *State) TakeCommands() []Command {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	err := s.sendWordsetCallback()
+	commands := s.commands
+	s.commands = nil
+	s.taken = s.lastID
+	return commands
+}
+
+// Applied acknowledges commands taken by sender.
+func (s *State) Applied() {
+	s.mx.Lock()
+	if s.applied == s.taken {
+		s.mx.Unlock()
+		return
+	}
+	s.applied = s.taken
+	ch := s.appliedCh
+	s.appliedCh = make(chan struct{})
+	s.mx.Unlock()
+	close(ch)
+}
+
+// WaitApplied waits until sender applies the command with the ID or the context is done.
+func (s *State) WaitApplied(ctx context.Context, id uint64) error {
+	for {
+		s.mx.Lock()
+		applied, ch := s.applied, s.appliedCh
+		s.mx.Unlock()
+		if applied >= id {
+			return nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+// exec applies the change and queues the command. Pending command of the same kind is replaced
+// because sender applies these commands by the current state, suspend and resume are of the same kind.
+func (s *State) exec(cmd Command, apply func()) Status {
+	s.mx.Lock()
+	if apply != nil {
+		apply()
+	}
+	queued := s.queue(cmd)
+	if queued {
+		s.lastID++
+	}
+	status := s.status()
+	status.Queued = queued
+	s.mx.Unlock()
+	if !queued {
+		return status
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return status
+}
+
+// queue adds the command to the queue, false is returned if the command is dropped.
+func (s *State) queue(cmd Command) bool {
+	if cmd == CommandTriggerNow || cmd == CommandSkipNext {
+		var count int
+		for _, c := range s.commands {
+			if c == CommandTriggerNow || c == CommandSkipNext {
+				count++
+			}
+		}
+		if count >= maxPendingCommands {
+			return false
+		}
+		s.commands = append(s.commands, cmd)
+		return true
+	}
+	commands := s.commands[:0]
+	for _, c := range s.commands {
+		if commandKind(c) != commandKind(cmd) {
+			commands = append(commands, c)
+		}
+	}
+	s.commands = append(commands, cmd)
+	return true
+}
+
+// commandKind returns command merged with the given one, suspend and resume are merged.
+func commandKind(cmd Command) Command {
+	if cmd == CommandResume {
+		return CommandSuspend
+	}
+	return cmd
+}
+
+// WordsetCallback returns card of the current source, nil card means there is nothing to send.
+func (s *State) WordsetCallback(ctx context.Context) (*notifier.Card, error) {
+	s.mx.Lock()
+	source := s.source
+	s.mx.Unlock()
+	card, err := source(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return card, nil
+}
+
+// SetWordsetCallback changes source of the sending cards.
+func (s *State) SetWordsetCallback(source Source) Status {
+	return s.exec(CommandSetSource, func() {
+		s.source = source
+	})
+}
+
+// ChangeTimeInterval changes schedule to sending every interval minutes.
+func (s *State) ChangeTimeInterval(interval time.Duration) Status {
+	return s.SetSchedule(schedule.Every(interval * time.Minute))
+}
+
+// SetSchedule changes sending schedule, suspended sending is resumed.
+func (s *State) SetSchedule(sched schedule.Schedule) Status {
+	return s.exec(CommandSetSchedule, func() {
+		s.schedule = sched
+		s.suspended = false
+	})
+}
+
+// SuspendWork suspends sending until resume or schedule change.
+func (s *State) SuspendWork() Status {
+	return s.exec(CommandSuspend, func() {
+		s.suspended = true
+	})
 }
 
-// ChangeCurrentWordset changes sending wordset callback.
-func (s *State) SetWordsetCallback(callback func() error) {
+// Resume resumes suspended sending by the current schedule.
+func (s *State) Resume() Status {
+	return s.exec(CommandResume, func() {
+		s.suspended = false
+	})
+}
+
+// TriggerNow asks sender to send card immediately, the schedule is kept.
+func (s *State) TriggerNow() Status {
+	return s.exec(CommandTriggerNow, nil)
+}
+
+// SkipNext asks sender to drop the next scheduled send.
+func (s *State) SkipNext() Status {
+	return s.exec(CommandSkipNext, nil)
+}
+
+// GetQuietHours returns quiet hours, sends inside quiet hours are moved to the window end if deferred.
+func (s *State) GetQuietHours() (*schedule.QuietHours, bool) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	s.sendWordsetCallback = callback
+	return s.quiet, s.deferQuiet
+}
+
+// SetQuietHours changes quiet hours, nil disables them.
+func (s *State) SetQuietHours(quiet *schedule.QuietHours, deferred bool) Status {
+	return s.exec(CommandReschedule, func() {
+		s.quiet = quiet
+		s.deferQuiet = deferred
+	})
 }
 
-// ChangeTimeInterval changes sending interval.
-func (s *State) ChangeTimeInterval(interval time.Duration) {
+// GetDND returns time until which sends are dropped.
+func (s *State) GetDND() time.Time {
 	s.mx.Lock()
 	defer s.mx.Unlock()
-	s.changeInterval <- interval * time.Minute
+	return s.dndUntil
 }
 
-// SuspendWork suspending sender.
-func (s *State) SuspendWork() {
-	s.suspend <- struct{}{}
+// SetDND drops sends until the time keeping the schedule, zero time disables do not disturb.
+func (s *State) SetDND(until time.Time) Status {
+	return s.exec(CommandReschedule, func() {
+		s.dndUntil = until
+	})
 }
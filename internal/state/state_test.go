@@ -0,0 +1,93 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommandsDoNotBlock(t *testing.T) {
+	st := NewState(60)
+	done := make(chan Status)
+	go func() {
+		// Nobody takes the commands, control methods must not block anyway.
+		for i := 0; i < 100; i++ {
+			st.ChangeTimeInterval(time.Duration(i + 1))
+			st.SuspendWork()
+		}
+		done <- st.TriggerNow()
+	}()
+	var status Status
+	select {
+	case status = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("control methods are blocked")
+	}
+	if !status.Suspended || !status.Queued || status.Pending != 3 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	commands := st.TakeCommands()
+	if len(commands) != 3 || commands[0] != CommandSetSchedule || commands[1] != CommandSuspend || commands[2] != CommandTriggerNow {
+		t.Fatalf("repeated commands are not merged in order, got %v", commands)
+	}
+	if status := st.Resume(); status.Suspended || status.Pending != 1 {
+		t.Errorf("unexpected status after resume %+v", status)
+	}
+	select {
+	case <-st.Commands():
+	default:
+		t.Error("sender is not notified about queued commands")
+	}
+}
+
+func TestCommandsQueueIsLimited(t *testing.T) {
+	st := NewState(60)
+	st.SuspendWork()
+	for i := 0; i < maxPendingCommands; i++ {
+		if status := st.TriggerNow(); !status.Queued {
+			t.Fatalf("command %d is not queued, status %+v", i, status)
+		}
+	}
+	if status := st.SkipNext(); status.Queued || status.Pending != maxPendingCommands+1 {
+		t.Errorf("expected command to be dropped from full queue, got %+v", status)
+	}
+	if status := st.Resume(); !status.Queued || status.Suspended || status.Pending != maxPendingCommands+1 {
+		t.Errorf("expected resume to replace pending suspend, got %+v", status)
+	}
+	commands := st.TakeCommands()
+	if commands[0] != CommandTriggerNow || commands[len(commands)-1] != CommandResume {
+		t.Errorf("unexpected commands order %v", commands)
+	}
+}
+
+func TestWaitApplied(t *testing.T) {
+	st := NewState(60)
+	status := st.SuspendWork()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := st.WaitApplied(ctx, status.ID); err == nil {
+		t.Fatal("expected command not taken by sender not to be applied")
+	}
+
+	applied := make(chan error, 1)
+	go func() {
+		applied <- st.WaitApplied(context.Background(), status.ID)
+	}()
+	st.TakeCommands()
+	next := st.Resume()
+	st.Applied()
+	select {
+	case err := <-applied:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("applied command is not acknowledged")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := st.WaitApplied(ctx, next.ID); err == nil {
+		t.Error("expected command queued after take not to be applied")
+	}
+}
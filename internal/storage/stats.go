@@ -0,0 +1,60 @@
+package storage
+
+import "time"
+
+const dayLayout = "2006-01-02"
+
+// Stats represents words review statistics.
+type Stats struct {
+	// Day of the last review.
+	Day string `yaml:"day"`
+	// Reviewed is a count of words reviewed on the Day.
+	Reviewed int `yaml:"reviewed"`
+	// Streak is a count of consecutive days with reviews ending on the Day.
+	Streak int `yaml:"streak"`
+	// Meanings keeps IDs of meanings reviewed on the Day.
+	Meanings []int `yaml:"meanings"`
+}
+
+// Review records the meaning reviewed at the time, false is returned if it is already counted on the day.
+func (s *Stats) Review(now time.Time, meaningID int) bool {
+	today := now.Format(dayLayout)
+	switch s.Day {
+	case today:
+		for _, id := range s.Meanings {
+			if id == meaningID {
+				return false
+			}
+		}
+		s.Reviewed++
+		s.Meanings = append(s.Meanings, meaningID)
+		return true
+	case now.AddDate(0, 0, -1).Format(dayLayout):
+		s.Streak++
+	default:
+		s.Streak = 1
+	}
+	s.Day = today
+	s.Reviewed = 1
+	s.Meanings = []int{meaningID}
+
+	return true
+}
+
+// ReviewedToday returns count of words reviewed on the day of the time.
+func (s Stats) ReviewedToday(now time.Time) int {
+	if s.Day != now.Format(dayLayout) {
+		return 0
+	}
+
+	return s.Reviewed
+}
+
+// CurrentStreak returns streak at the time, streak is kept until the end of the day after the last review.
+func (s Stats) CurrentStreak(now time.Time) int {
+	if s.Day != now.Format(dayLayout) && s.Day != now.AddDate(0, 0, -1).Format(dayLayout) {
+		return 0
+	}
+
+	return s.Streak
+}
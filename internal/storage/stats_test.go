@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsReview(t *testing.T) {
+	day := time.Date(2020, 5, 10, 9, 0, 0, 0, time.UTC)
+	var s Stats
+
+	s.Review(day, 1)
+	s.Review(day.Add(time.Hour), 2)
+	if s.Review(day.Add(2*time.Hour), 1) {
+		t.Error("expected repeated review of the meaning not to be counted")
+	}
+	if s.ReviewedToday(day) != 2 || s.CurrentStreak(day) != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	next := day.AddDate(0, 0, 1)
+	if s.ReviewedToday(next) != 0 || s.CurrentStreak(next) != 1 {
+		t.Fatalf("streak is lost before the day is over, stats %+v", s)
+	}
+	if !s.Review(next, 1) {
+		t.Error("expected the meaning to be counted on the next day")
+	}
+	if s.ReviewedToday(next) != 1 || s.CurrentStreak(next) != 2 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+
+	gap := next.AddDate(0, 0, 2)
+	if s.CurrentStreak(gap) != 0 {
+		t.Fatalf("expected broken streak, stats %+v", s)
+	}
+	s.Review(gap, 1)
+	if s.CurrentStreak(gap) != 1 {
+		t.Fatalf("expected new streak, stats %+v", s)
+	}
+}
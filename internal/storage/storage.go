@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 	"time"
+
+	"github.com/pachmu/skyeng-push-notificator/internal/srs"
 )
 
 type Data struct {
@@ -14,6 +16,36 @@ type Data struct {
 	Interval    time.Duration `yaml:"interval"`
 	Random      bool          `yaml:"random"`
 	WordsetName string        `yaml:"wordset_name"`
+	SkipLearned bool          `yaml:"skip_learned"`
+	Stats       Stats         `yaml:"stats"`
+	// Schedule overrides interval if set, it is parsed in the Timezone.
+	Schedule string    `yaml:"schedule"`
+	Timezone string    `yaml:"timezone"`
+	Quiet    Quiet     `yaml:"quiet"`
+	DNDUntil time.Time `yaml:"dnd_until"`
+	// SRS enables spaced repetition, the most due word is pushed and graded by user.
+	SRS bool `yaml:"srs"`
+	// Reviews keeps spaced repetition state by meaning ID.
+	Reviews map[int]srs.Item `yaml:"reviews"`
+	// Known keeps IDs of meanings user marked as known, they are not pushed anymore.
+	Known []int `yaml:"known"`
+	// Suspended keeps sending suspended after restart.
+	Suspended bool `yaml:"suspended"`
+}
+
+// Copy returns deep copy of the data.
+func (d *Data) Copy() *Data {
+	c := *d
+	if d.Reviews != nil {
+		c.Reviews = make(map[int]srs.Item, len(d.Reviews))
+		for id, item := range d.Reviews {
+			c.Reviews[id] = item
+		}
+	}
+	c.Known = append([]int(nil), d.Known...)
+	c.Stats.Meanings = append([]int(nil), d.Stats.Meanings...)
+
+	return &c
 }
 
 type Storage interface {
@@ -63,3 +95,12 @@ func (s *yamlStorage) WriteData(data *Data) error {
 	}
 	return nil
 }
+
+// Quiet represents quiet hours windows like "22:00-08:00" in the Data timezone.
+type Quiet struct {
+	Weekdays string `yaml:"weekdays"`
+	// Weekends window, weekdays window is used if empty.
+	Weekends string `yaml:"weekends"`
+	// Defer moves sends from quiet hours to the window end instead of dropping them.
+	Defer bool `yaml:"defer"`
+}
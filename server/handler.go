@@ -1,25 +1,65 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"github.com/pachmu/skyeng-push-notificator/internal/sender"
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
 	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
+	"github.com/pachmu/skyeng-push-notificator/internal/state"
 	log "github.com/sirupsen/logrus"
 	"net/http"
 )
 
+// WordsetSetter changes wordset used for periodic sending.
+type WordsetSetter interface {
+	SetWordset(ctx context.Context, wordsetID int, wordsetName string) error
+}
+
+// SendingController controls periodic sending, methods return the resulting sending state.
+type SendingController interface {
+	Suspend(ctx context.Context) (state.Status, error)
+	Resume(ctx context.Context) (state.Status, error)
+	SendNow(ctx context.Context) (state.Status, error)
+	SkipNext(ctx context.Context) (state.Status, error)
+}
+
+// sendingStatus represents response of sending control endpoints.
+type sendingStatus struct {
+	Suspended bool `json:"suspended"`
+	// Pending is a number of commands not applied by sender yet.
+	Pending int `json:"pending"`
+	// Queued is false if the command is dropped because too many commands are pending.
+	Queued bool `json:"queued"`
+	// Applied is false if sender has not applied the queued command in time, it is applied later.
+	Applied bool `json:"applied"`
+}
+
+// AnswerRecorder records user answers to pushed words.
+type AnswerRecorder interface {
+	MarkKnown(ctx context.Context, meaningID int) error
+}
+
+// ExamplesSender sends usage examples of the meaning to user.
+type ExamplesSender interface {
+	SendExamples(ctx context.Context, m skyeng.Meaning) error
+}
+
 type handler struct {
-	skyengClient skyeng.Client
-	user         string
-	sender       *sender.Sender
+	skyengClient   skyeng.Client
+	user           string
+	controller     SendingController
+	wordsetSetter  WordsetSetter
+	answers        AnswerRecorder
+	examplesSender ExamplesSender
+	ntfySecret     string
 }
 
 func (h *handler) getWordsets(w http.ResponseWriter, req *http.Request) {
 	if !h.auth(w, req) {
 		return
 	}
-	wordsets, err := h.skyengClient.GetWordsets(0)
+	wordsets, err := skyeng.AllWordsets(req.Context(), h.skyengClient).Collect()
 	if err != nil {
 		log.Error("failed to get wordsets from skyeng, got ", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -39,20 +79,16 @@ func (h *handler) setWordset(w http.ResponseWriter, req *http.Request) {
 	if !h.auth(w, req) {
 		return
 	}
-	var wordset map[string]int
+	var wordset skyeng.Wordset
 	err := json.NewDecoder(req.Body).Decode(&wordset)
-	ID, ok := wordset["ID"]
-	if !ok {
+	if err != nil || wordset.ID == 0 {
 		log.Error("failed to get ID from request, got ", err)
 		w.WriteHeader(http.StatusBadRequest)
 
 		return
 	}
-	_, err = h.skyengClient.GetWords(
-		skyeng.Wordset{
-			ID: ID,
-		},
-	)
+	ID := wordset.ID
+	_, err = h.skyengClient.GetWords(req.Context(), wordset)
 	if err != nil {
 		log.Errorf("failed to get words for wordset %d, got %v", ID, err)
 		if errors.Is(err, skyeng.ErrWordsetNotFound) {
@@ -63,15 +99,112 @@ func (h *handler) setWordset(w http.ResponseWriter, req *http.Request) {
 
 		return
 	}
-	h.sender.ChangeCurrentWordset(ID)
+	err = h.wordsetSetter.SetWordset(req.Context(), ID, wordset.Title)
+	if err != nil {
+		log.Errorf("failed to set wordset %d, got %v", ID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
 }
 
-func (h *handler) stopSending(w http.ResponseWriter, req *http.Request) {
-	if !h.auth(w, req) {
+// control returns handler executing sending command and responding with the resulting state.
+// 202 status is responded if sender has not applied the command in time and 429 if the command is dropped.
+func (h *handler) control(command func(ctx context.Context) (state.Status, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !h.auth(w, req) {
+			return
+		}
+		status, err := command(req.Context())
+		if err != nil {
+			log.Errorf("failed to execute %s, got %v", req.URL.Path, err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+		switch {
+		case status.Applied:
+			log.Infof("Sending command %s applied", req.URL.Path)
+		case status.Queued:
+			log.Infof("Sending command %s queued", req.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			log.Warnf("Sending command %s dropped, %d commands are pending", req.URL.Path, status.Pending)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		err = json.NewEncoder(w).Encode(sendingStatus{Suspended: status.Suspended, Pending: status.Pending, Queued: status.Queued, Applied: status.Applied})
+		if err != nil {
+			log.Error("failed to encode sending status, got ", err)
+		}
+	}
+}
+
+func (h *handler) ntfyKnown(w http.ResponseWriter, req *http.Request) {
+	answer, ok := h.ntfyAnswer(w, req)
+	if !ok {
+		return
+	}
+	err := h.answers.MarkKnown(req.Context(), answer.MeaningID)
+	if err != nil {
+		log.Errorf("failed to mark meaning %d as known, got %v", answer.MeaningID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+	log.Infof("Meaning %d marked as known", answer.MeaningID)
+}
+
+func (h *handler) ntfyExamples(w http.ResponseWriter, req *http.Request) {
+	answer, ok := h.ntfyAnswer(w, req)
+	if !ok {
+		return
+	}
+	meanings, err := h.skyengClient.GetMeaning(req.Context(), skyeng.Word{MeaningID: answer.MeaningID})
+	if err != nil {
+		log.Errorf("failed to get meaning %d, got %v", answer.MeaningID, err)
+		if errors.Is(err, skyeng.ErrMeaningNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
 		return
 	}
-	h.sender.SuspendWork()
-	log.Info("Sending stopped")
+	for _, m := range meanings.Data {
+		err = h.examplesSender.SendExamples(req.Context(), m)
+		if err != nil {
+			log.Errorf("failed to send examples of meaning %d, got %v", m.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+	}
+}
+
+// ntfyAnswer decodes ntfy action, actions are authorized by the meaning signature instead of
+// the user since they are visible to every topic subscriber.
+func (h *handler) ntfyAnswer(w http.ResponseWriter, req *http.Request) (*notifier.NtfyAnswer, bool) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return nil, false
+	}
+	var answer notifier.NtfyAnswer
+	err := json.NewDecoder(req.Body).Decode(&answer)
+	if err != nil || answer.MeaningID == 0 {
+		log.Error("failed to get meaning ID from request, got ", err)
+		w.WriteHeader(http.StatusBadRequest)
+
+		return nil, false
+	}
+	if !notifier.VerifyNtfyAnswer(h.ntfySecret, &answer) {
+		log.Errorf("invalid signature of ntfy action on meaning %d", answer.MeaningID)
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return nil, false
+	}
+
+	return &answer, true
 }
 
 func (h *handler) auth(w http.ResponseWriter, req *http.Request) bool {
@@ -1,10 +1,11 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
-	"github.com/pachmu/skyeng-push-notificator/internal/sender"
+	"github.com/pachmu/skyeng-push-notificator/internal/notifier"
 	"github.com/pachmu/skyeng-push-notificator/internal/skyeng"
 	"github.com/pkg/errors"
 )
@@ -12,20 +13,49 @@ import (
 type Server struct {
 	Addr string
 	Port int
+	// Answers records answers of ntfy actions, ntfy endpoints are not served if nil.
+	Answers AnswerRecorder
+	// Examples sends examples requested by ntfy actions.
+	Examples ExamplesSender
+	// NtfySecret verifies signatures of ntfy actions, ntfy endpoints are not served if empty.
+	NtfySecret string
 }
 
-func (s *Server) Serve(user string, client skyeng.Client, sender *sender.Sender) error {
+// Serve serves http api until context is done.
+func (s *Server) Serve(ctx context.Context, user string, client skyeng.Client, controller SendingController, setter WordsetSetter) error {
 	h := handler{
-		skyengClient: client,
-		sender:       sender,
-		user:         user,
+		skyengClient:   client,
+		controller:     controller,
+		wordsetSetter:  setter,
+		user:           user,
+		answers:        s.Answers,
+		examplesSender: s.Examples,
+		ntfySecret:     s.NtfySecret,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/get_wordsets", h.getWordsets)
+	mux.HandleFunc("/set_wordset", h.setWordset)
+	mux.HandleFunc("/stop_sending", h.control(controller.Suspend))
+	mux.HandleFunc("/resume_sending", h.control(controller.Resume))
+	mux.HandleFunc("/send_now", h.control(controller.SendNow))
+	mux.HandleFunc("/skip_next", h.control(controller.SkipNext))
+	if s.Answers != nil && s.NtfySecret != "" {
+		mux.HandleFunc(notifier.NtfyKnownPath, h.ntfyKnown)
+	}
+	if s.Examples != nil && s.NtfySecret != "" {
+		mux.HandleFunc(notifier.NtfyExamplesPath, h.ntfyExamples)
 	}
-	http.HandleFunc("/get_wordsets", h.getWordsets)
-	http.HandleFunc("/set_wordset", h.setWordset)
-	http.HandleFunc("/stop_sending", h.stopSending)
 
-	err := http.ListenAndServe(fmt.Sprintf("%s:%d", s.Addr, s.Port), nil)
-	if err != nil {
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", s.Addr, s.Port),
+		Handler: mux,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	err := srv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		return errors.WithStack(err)
 	}
 	return nil